@@ -0,0 +1,197 @@
+// Package attach loads workspace files (images, PDFs, audio, video and text)
+// so they can be sent to the model along with a message
+package attach
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+
+	"github.com/haljac/gemini-tui/tools"
+)
+
+const (
+	MaxFileSize  = 10 * 1024 * 1024 // Per file
+	MaxTotalSize = 20 * 1024 * 1024 // Per request, counting files attached earlier; Gemini's limit for inline data
+)
+
+// inlineTypes are the image and document types Gemini accepts as inline
+// data. Audio and video are accepted too; any other file must be text.
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/webp":      true,
+	"image/heic":      true,
+	"image/heif":      true,
+	"application/pdf": true,
+}
+
+// extraTypes covers extensions that the mime package may not know
+var extraTypes = map[string]string{
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".webp": "image/webp",
+}
+
+// Attachment is a file to send with a message
+type Attachment struct {
+	Path     string // As given, relative to the project
+	MIMEType string // text/plain for any text file
+	Data     []byte
+}
+
+// Load reads path from the project, checking it against the executor's
+// sandbox and the size limit, and works out its type
+func Load(executor *tools.Executor, path string) (*Attachment, error) {
+	resolved, err := executor.ResolvePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't attach %s: %w", path, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("can't attach %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("can't attach %s: it is a directory", path)
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("can't attach %s: %s is over the %s limit", path, formatSize(info.Size()), formatSize(MaxFileSize))
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("can't attach %s: %w", path, err)
+	}
+
+	a := &Attachment{Path: path, Data: data, MIMEType: detectType(path, data)}
+	text := utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+	binaryImage := strings.HasPrefix(a.MIMEType, "image/") && a.MIMEType != "image/svg+xml"
+	recording := strings.HasPrefix(a.MIMEType, "audio/") || strings.HasPrefix(a.MIMEType, "video/")
+	switch {
+	case inlineTypes[a.MIMEType]:
+		// Images and PDFs are sent as they are
+	case text && !binaryImage:
+		// Includes source files whose extensions are also used for video,
+		// such as .ts and .mts
+		a.MIMEType = "text/plain"
+	case recording && !text:
+		// Audio and video are sent as they are
+	default:
+		return nil, fmt.Errorf("can't attach %s: %s files aren't supported (use images, PDFs, audio, video or text)", path, a.MIMEType)
+	}
+	return a, nil
+}
+
+// detectType returns the MIME type from the file extension, falling back to
+// sniffing the contents
+func detectType(path string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	mimeType := extraTypes[ext]
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return mimeType
+}
+
+// Text reports whether the file is sent as text rather than inline data
+func (a *Attachment) Text() bool {
+	return a.MIMEType == "text/plain"
+}
+
+// Label describes the attachment for its chip, e.g. "diagram.png · 120 KB"
+func (a *Attachment) Label() string {
+	return fmt.Sprintf("%s · %s", filepath.Base(a.Path), formatSize(int64(len(a.Data))))
+}
+
+// Parts returns the parts that carry the attachment: a line naming the
+// file followed by its data, or the contents of a text file
+func (a *Attachment) Parts() []*genai.Part {
+	if a.Text() {
+		return []*genai.Part{{Text: fmt.Sprintf("<file path=%q>\n%s\n</file>", a.Path, a.Data)}}
+	}
+	return []*genai.Part{
+		{Text: fmt.Sprintf("Attached file %s (%s):", a.Path, a.MIMEType)},
+		genai.NewPartFromBytes(a.Data, a.MIMEType),
+	}
+}
+
+// TotalSize returns the combined size of attachments
+func TotalSize(attachments []*Attachment) int64 {
+	var total int64
+	for _, a := range attachments {
+		total += int64(len(a.Data))
+	}
+	return total
+}
+
+// InlineSize returns the combined size of the files attached to messages
+// in contents. They are sent again with every request.
+func InlineSize(contents []*genai.Content) int64 {
+	var total int64
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			if part.InlineData != nil {
+				total += int64(len(part.InlineData.Data))
+			}
+		}
+	}
+	return total
+}
+
+// CheckTotal returns an error if attachments are too large to send along
+// with the earlier attachments in history
+func CheckTotal(attachments []*Attachment, history []*genai.Content) error {
+	total, earlier := TotalSize(attachments), InlineSize(history)
+	switch {
+	case total > MaxTotalSize:
+		return fmt.Errorf("attachments total %s, over the %s limit per request", formatSize(total), formatSize(MaxTotalSize))
+	case total+earlier > MaxTotalSize:
+		return fmt.Errorf("attachments total %s and the conversation already holds %s of attached files, over the %s limit per request (/compact drops files from older turns)", formatSize(total), formatSize(earlier), formatSize(MaxTotalSize))
+	}
+	return nil
+}
+
+// References returns the @path words in text that name files in the
+// project. Other words starting with @, such as @mentions, are left alone.
+func References(executor *tools.Executor, text string) []string {
+	var paths []string
+	for _, word := range strings.Fields(text) {
+		path, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		path = strings.TrimRight(path, ".,;:!?)]}'\"")
+		if path == "" {
+			continue
+		}
+		resolved, err := executor.ResolvePath(path)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(resolved); err == nil && info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%d KB", n/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,110 @@
+package attach
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+
+	"github.com/haljac/gemini-tui/tools"
+)
+
+// project creates a workspace with a few files, plus a file outside it
+func project(t *testing.T) *tools.Executor {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "project")
+	files := map[string][]byte{
+		"project/shot.png":     append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...),
+		"project/spec.pdf":     []byte("%PDF-1.7\n"),
+		"project/notes.md":     []byte("# Notes\n"),
+		"project/mod.mts":      []byte("export const x = 1;\n"),
+		"project/clip.mp4":     append([]byte("\x00\x00\x00\x18ftypmp42"), make([]byte, 100)...),
+		"project/blob.bin":     {0, 1, 2, 3},
+		"project/anim.gif":     []byte("GIF89a"),
+		"project/big.txt":      make([]byte, MaxFileSize+1),
+		"outside/secret.txt":   []byte("top secret"),
+		"project/docs/api.txt": []byte("GET /users"),
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := tools.NewExecutor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestLoadDetectsTypes(t *testing.T) {
+	e := project(t)
+	for path, want := range map[string]string{
+		"shot.png": "image/png",
+		"spec.pdf": "application/pdf",
+		"notes.md": "text/plain",
+		"mod.mts":  "text/plain",
+		"clip.mp4": "video/mp4",
+	} {
+		a, err := Load(e, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if a.MIMEType != want {
+			t.Errorf("%s: got %s, want %s", path, a.MIMEType, want)
+		}
+	}
+
+	png, _ := Load(e, "shot.png")
+	parts := png.Parts()
+	if len(parts) != 2 || parts[1].InlineData == nil || parts[1].InlineData.MIMEType != "image/png" {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+}
+
+func TestLoadRejectsUnsupportedFiles(t *testing.T) {
+	e := project(t)
+	for path, want := range map[string]string{
+		"blob.bin":              "aren't supported",
+		"anim.gif":              "aren't supported",
+		"big.txt":               "over the",
+		"docs":                  "directory",
+		"../outside/secret.txt": "can't attach",
+	} {
+		_, err := Load(e, path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error containing %q", path, err, want)
+		}
+	}
+}
+
+func TestReferencesFindsProjectFiles(t *testing.T) {
+	e := project(t)
+	got := References(e, "Compare @shot.png with @docs/api.txt, ask @alice and skip @../outside/secret.txt")
+	if want := []string{"shot.png", "docs/api.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckTotalCountsEarlierAttachments(t *testing.T) {
+	file := &Attachment{Path: "clip.mp4", MIMEType: "video/mp4", Data: make([]byte, MaxFileSize)}
+	if err := CheckTotal([]*Attachment{file}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	history := []*genai.Content{{Role: "user", Parts: file.Parts()}, {Role: "user", Parts: file.Parts()}}
+	if got := InlineSize(history); got != 2*MaxFileSize {
+		t.Fatalf("InlineSize = %d", got)
+	}
+	if err := CheckTotal([]*Attachment{file}, history); err == nil || !strings.Contains(err.Error(), "already holds 20.0 MB") {
+		t.Fatalf("got %v, want an error about the earlier attachments", err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package compact keeps the conversation history within the model's context
+// window. Tool outputs and attached files in older turns are shortened or
+// dropped first; if that isn't enough, the older turns are replaced by a
+// summary written by the model. The most recent turns are always kept
+// verbatim.
+package compact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+
+	"github.com/haljac/gemini-tui/internal/provider"
+	"github.com/haljac/gemini-tui/internal/usage"
+)
+
+// DefaultWindow is the context size assumed for models that aren't Gemini
+// models, unless the config sets one
+const DefaultWindow = 32768
+
+// geminiWindow is the context size of the Gemini models
+const geminiWindow = 1048576
+
+// inlineDataTokens is what an attached image costs, and the least any
+// attached file does
+const inlineDataTokens = 258
+
+// inlineBytesPerToken approximates how many bytes of a larger attached file,
+// such as a PDF or a recording, make up a token
+const inlineBytesPerToken = 400
+
+// staleOutputLimit is how many characters of an older tool output are kept
+const staleOutputLimit = 1000
+
+// summaryPrefix marks the message that holds the summary of compacted turns
+const summaryPrefix = "[Summary of the earlier conversation, which was compacted to save context]\n\n"
+
+const summaryPrompt = `Summarize the conversation below between a user and a coding agent, so that the agent can continue the work with only your summary and the latest messages. Keep:
+
+- what the user asked for and any preferences or constraints they stated
+- decisions made and the reasons for them
+- files that were read, created or changed, and what was learned about them
+- commands that were run and their outcomes, including errors
+- what is still unfinished
+
+Be concise and specific. Use bullet points, and name files, functions and commands exactly.`
+
+// Window returns the context size of model, in tokens
+func Window(model string) int {
+	if strings.HasPrefix(model, "gemini-") {
+		return geminiWindow
+	}
+	return DefaultWindow
+}
+
+// Estimate approximates the number of tokens in contents, at about four
+// characters per token
+func Estimate(contents []*genai.Content) int {
+	chars := 0
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			chars += len(part.Text)
+			if part.FunctionCall != nil {
+				chars += len(part.FunctionCall.Name) + jsonLen(part.FunctionCall.Args)
+			}
+			if part.FunctionResponse != nil {
+				chars += len(part.FunctionResponse.Name) + jsonLen(part.FunctionResponse.Response)
+			}
+			if part.InlineData != nil {
+				chars += max(inlineDataTokens, len(part.InlineData.Data)/inlineBytesPerToken) * 4
+			}
+		}
+	}
+	return chars / 4
+}
+
+func jsonLen(v any) int {
+	data, _ := json.Marshal(v)
+	return len(data)
+}
+
+// Options controls how a history is compacted
+type Options struct {
+	KeepTurns int // Most recent user turns kept verbatim
+	// Limit is the token budget. If shortening old tool outputs brings the
+	// history within it, no summary is written. Zero always summarizes.
+	Limit int
+}
+
+// Result is a compacted history
+type Result struct {
+	Contents   []*genai.Content
+	Before     int          // Estimated tokens before compacting
+	After      int          // Estimated tokens after compacting
+	Turns      int          // Older turns that were compacted
+	Summarized bool         // The older turns were replaced by a summary
+	Usage      usage.Tokens // Tokens used to write the summary
+}
+
+// Compact shortens contents, keeping the last opts.KeepTurns turns as they
+// are. The summary, if one is needed, is written by model. If there are no
+// older turns the history is returned unchanged with Turns set to zero.
+func Compact(ctx context.Context, prov provider.Provider, model string, contents []*genai.Content, opts Options) (*Result, error) {
+	result := &Result{Contents: contents, Before: Estimate(contents)}
+	result.After = result.Before
+
+	split, turns := Split(contents, opts.KeepTurns)
+	if turns == 0 {
+		return result, nil
+	}
+	result.Turns = turns
+
+	old := DropInlineData(ShrinkToolOutputs(contents[:split], staleOutputLimit))
+	recent := contents[split:]
+	shrunk := append(old, recent...)
+	if opts.Limit > 0 && Estimate(shrunk) <= opts.Limit {
+		result.Contents = shrunk
+		result.After = Estimate(shrunk)
+		return result, nil
+	}
+
+	summary, tokens, err := Summarize(ctx, prov, model, old)
+	result.Usage = tokens
+	if err != nil {
+		return result, err
+	}
+	compacted := []*genai.Content{
+		{Role: "user", Parts: []*genai.Part{{Text: summaryPrefix + summary}}},
+		{Role: "model", Parts: []*genai.Part{{Text: "Understood. I'll continue from there."}}},
+	}
+	compacted = append(compacted, recent...)
+	result.Contents = compacted
+	result.After = Estimate(compacted)
+	result.Summarized = true
+	return result, nil
+}
+
+// Split returns the index where the last keep turns begin, and how many
+// turns come before it. A turn begins with a message typed by the user,
+// as opposed to the function responses that continue a turn. The turn in
+// progress is always kept, so keep is at least one.
+func Split(contents []*genai.Content, keep int) (index, turns int) {
+	keep = max(keep, 1)
+	var starts []int
+	for i, content := range contents {
+		if isTurnStart(content) {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts) <= keep {
+		return 0, 0
+	}
+	return starts[len(starts)-keep], len(starts) - keep
+}
+
+func isTurnStart(content *genai.Content) bool {
+	if content.Role != "user" {
+		return false
+	}
+	for _, part := range content.Parts {
+		if part.FunctionResponse != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ShrinkToolOutputs returns a copy of contents in which string values of
+// function responses longer than limit characters are cut short. contents
+// itself is not modified.
+func ShrinkToolOutputs(contents []*genai.Content, limit int) []*genai.Content {
+	shrunk := make([]*genai.Content, len(contents))
+	for i, content := range contents {
+		shrunk[i] = content
+		for j, part := range content.Parts {
+			if part.FunctionResponse == nil || !hasLongString(part.FunctionResponse.Response, limit) {
+				continue
+			}
+			if shrunk[i] == content {
+				copied := *content
+				copied.Parts = append([]*genai.Part(nil), content.Parts...)
+				shrunk[i] = &copied
+			}
+			response := *part.FunctionResponse
+			response.Response = make(map[string]any, len(part.FunctionResponse.Response))
+			for key, value := range part.FunctionResponse.Response {
+				if s, ok := value.(string); ok && len(s) > limit {
+					cut := cutRunes(s, limit)
+					value = cut + fmt.Sprintf("\n[... %d more characters removed to save context]", len(s)-len(cut))
+				}
+				response.Response[key] = value
+			}
+			copiedPart := *part
+			copiedPart.FunctionResponse = &response
+			shrunk[i].Parts[j] = &copiedPart
+		}
+	}
+	return shrunk
+}
+
+// DropInlineData returns a copy of contents in which attached files are
+// replaced by a note saying what they were. contents itself is not modified.
+func DropInlineData(contents []*genai.Content) []*genai.Content {
+	dropped := make([]*genai.Content, len(contents))
+	for i, content := range contents {
+		dropped[i] = content
+		for j, part := range content.Parts {
+			if part.InlineData == nil {
+				continue
+			}
+			if dropped[i] == content {
+				copied := *content
+				copied.Parts = append([]*genai.Part(nil), content.Parts...)
+				dropped[i] = &copied
+			}
+			dropped[i].Parts[j] = &genai.Part{Text: fmt.Sprintf("[%s file of %d bytes removed to save context]", part.InlineData.MIMEType, len(part.InlineData.Data))}
+		}
+	}
+	return dropped
+}
+
+func hasLongString(response map[string]any, limit int) bool {
+	for _, value := range response {
+		if s, ok := value.(string); ok && len(s) > limit {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize asks model for a summary of contents. The history is sent as a
+// plain transcript, so it works without the tool declarations.
+func Summarize(ctx context.Context, prov provider.Provider, model string, contents []*genai.Content) (string, usage.Tokens, error) {
+	request := []*genai.Content{{
+		Role:  "user",
+		Parts: []*genai.Part{{Text: summaryPrompt + "\n\n<conversation>\n" + Transcript(contents) + "</conversation>"}},
+	}}
+
+	var summary strings.Builder
+	var tokens usage.Tokens
+	for resp, err := range prov.GenerateContentStream(ctx, model, request, nil) {
+		if err != nil {
+			return "", tokens, fmt.Errorf("summarizing the conversation: %w", err)
+		}
+		if resp.UsageMetadata != nil {
+			tokens = usage.FromMetadata(resp.UsageMetadata)
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			continue
+		}
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if !part.Thought {
+				summary.WriteString(part.Text)
+			}
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", tokens, fmt.Errorf("summarizing the conversation: the model returned no summary")
+	}
+	return strings.TrimSpace(summary.String()), tokens, nil
+}
+
+// Transcript renders contents as plain text, one line per message, tool
+// call or tool result
+func Transcript(contents []*genai.Content) string {
+	var sb strings.Builder
+	for _, content := range contents {
+		speaker := "User"
+		if content.Role == "model" {
+			speaker = "Agent"
+		}
+		for _, part := range content.Parts {
+			switch {
+			case part.Thought:
+				continue
+			case part.InlineData != nil:
+				fmt.Fprintf(&sb, "%s attached a file (%s)\n", speaker, part.InlineData.MIMEType)
+			case part.FunctionCall != nil:
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				fmt.Fprintf(&sb, "Tool call: %s %s\n", part.FunctionCall.Name, clip(string(args)))
+			case part.FunctionResponse != nil:
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				fmt.Fprintf(&sb, "Tool result (%s): %s\n", part.FunctionResponse.Name, clip(string(response)))
+			case part.Text != "":
+				fmt.Fprintf(&sb, "%s: %s\n", speaker, part.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// clip shortens long tool arguments and results in a transcript
+func clip(s string) string {
+	if len(s) <= staleOutputLimit {
+		return s
+	}
+	cut := cutRunes(s, staleOutputLimit)
+	return cut + fmt.Sprintf("... (%d more characters)", len(s)-len(cut))
+}
+
+// cutRunes shortens s to at most n bytes without splitting a UTF-8 sequence
+func cutRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,150 @@
+package compact
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+// fakeProvider answers every request with the same text
+type fakeProvider struct {
+	reply    string
+	requests [][]*genai.Content
+}
+
+func (f *fakeProvider) Name() string     { return "fake" }
+func (f *fakeProvider) Models() []string { return []string{"fake"} }
+
+func (f *fakeProvider) GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	f.requests = append(f.requests, contents)
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		yield(&genai.GenerateContentResponse{
+			Candidates:    []*genai.Candidate{{Content: genai.NewContentFromText(f.reply, "model")}},
+			UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 100, CandidatesTokenCount: 10},
+		}, nil)
+	}
+}
+
+// history returns three turns, each reading a large file
+func history() []*genai.Content {
+	var contents []*genai.Content
+	for _, question := range []string{"first", "second", "third"} {
+		contents = append(contents,
+			genai.NewContentFromText(question, "user"),
+			&genai.Content{Role: "model", Parts: []*genai.Part{genai.NewPartFromFunctionCall("read_file", map[string]any{"path": question + ".go"})}},
+			&genai.Content{Role: "user", Parts: []*genai.Part{genai.NewPartFromFunctionResponse("read_file", map[string]any{"content": strings.Repeat("x", 8000)})}},
+			genai.NewContentFromText("answer to "+question, "model"),
+		)
+	}
+	return contents
+}
+
+func TestSplitKeepsRecentTurns(t *testing.T) {
+	contents := history()
+
+	index, turns := Split(contents, 2)
+	if index != 4 || turns != 1 {
+		t.Fatalf("got index %d, turns %d", index, turns)
+	}
+	if _, turns := Split(contents, 3); turns != 0 {
+		t.Fatalf("expected nothing to compact, got %d turns", turns)
+	}
+}
+
+func TestCompactShrinksOldToolOutputsFirst(t *testing.T) {
+	contents := history()
+	prov := &fakeProvider{reply: "summary"}
+
+	result, err := Compact(context.Background(), prov, "fake", contents, Options{KeepTurns: 2, Limit: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summarized || len(prov.requests) != 0 {
+		t.Fatal("expected no summary")
+	}
+	if result.After >= result.Before || len(result.Contents) != len(contents) {
+		t.Fatalf("got %d contents, %d -> %d tokens", len(result.Contents), result.Before, result.After)
+	}
+	old := result.Contents[2].Parts[0].FunctionResponse.Response["content"].(string)
+	if !strings.HasPrefix(old, strings.Repeat("x", staleOutputLimit)+"\n[... 7000 more characters removed") {
+		t.Fatalf("old output not shortened: %.40q", old)
+	}
+	// The recent turns and the original history are untouched
+	if recent := result.Contents[6].Parts[0].FunctionResponse.Response["content"].(string); len(recent) != 8000 {
+		t.Fatalf("recent output changed to %d characters", len(recent))
+	}
+	if original := contents[2].Parts[0].FunctionResponse.Response["content"].(string); len(original) != 8000 {
+		t.Fatal("original history was modified")
+	}
+}
+
+func TestShrinkKeepsMultibyteCharactersWhole(t *testing.T) {
+	contents := []*genai.Content{{Role: "user", Parts: []*genai.Part{
+		genai.NewPartFromFunctionResponse("read_file", map[string]any{"content": strings.Repeat("é", 10)}),
+	}}}
+	shrunk := ShrinkToolOutputs(contents, 5)
+	got := shrunk[0].Parts[0].FunctionResponse.Response["content"].(string)
+	if !utf8.ValidString(got) || !strings.HasPrefix(got, "éé\n") {
+		t.Fatalf("got %q", got)
+	}
+	if clipped := clip(strings.Repeat("é", staleOutputLimit)); !utf8.ValidString(clipped) {
+		t.Fatalf("clip split a character: %q", clipped[staleOutputLimit-2:staleOutputLimit+2])
+	}
+}
+
+func TestCompactSummarizesOldTurns(t *testing.T) {
+	contents := history()
+	prov := &fakeProvider{reply: "- read first.go"}
+
+	result, err := Compact(context.Background(), prov, "fake", contents, Options{KeepTurns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Summarized || result.Turns != 2 || result.Usage.Input != 100 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Contents) != 6 || result.Contents[0].Parts[0].Text != summaryPrefix+"- read first.go" {
+		t.Fatalf("unexpected history: %d contents, first %q", len(result.Contents), result.Contents[0].Parts[0].Text)
+	}
+	if result.Contents[2].Parts[0].Text != "third" {
+		t.Fatalf("latest turn not kept: %q", result.Contents[2].Parts[0].Text)
+	}
+	transcript := prov.requests[0][0].Parts[0].Text
+	if !strings.Contains(transcript, "User: second") || !strings.Contains(transcript, `Tool call: read_file {"path":"first.go"}`) || strings.Contains(transcript, "third") {
+		t.Fatalf("unexpected transcript:\n%s", transcript)
+	}
+}
+
+func TestCompactDropsOldAttachments(t *testing.T) {
+	attached := func(question string) *genai.Content {
+		return &genai.Content{Role: "user", Parts: []*genai.Part{
+			{Text: question},
+			genai.NewPartFromBytes(make([]byte, 4_000_000), "application/pdf"),
+		}}
+	}
+	contents := []*genai.Content{
+		attached("first"), genai.NewContentFromText("answer", "model"),
+		attached("second"), genai.NewContentFromText("answer", "model"),
+	}
+	if got := Estimate(contents); got < 20000 {
+		t.Fatalf("estimate of %d tokens ignores the size of the files", got)
+	}
+
+	result, err := Compact(context.Background(), &fakeProvider{}, "fake", contents, Options{KeepTurns: 1, Limit: 15000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summarized || result.After >= result.Before {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if part := result.Contents[0].Parts[1]; part.InlineData != nil || part.Text != "[application/pdf file of 4000000 bytes removed to save context]" {
+		t.Fatalf("old attachment kept: %+v", part)
+	}
+	if result.Contents[2].Parts[1].InlineData == nil || contents[0].Parts[1].InlineData == nil {
+		t.Fatal("the latest turn or the original history lost its attachment")
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/haljac/gemini-tui/internal/params"
+	"github.com/haljac/gemini-tui/internal/usage"
+)
+
+// Config holds user settings loaded from config.toml
+type Config struct {
+	Model      string           `toml:"model"`
+	Thinking   ThinkingConfig   `toml:"thinking"`
+	Tools      ToolsConfig      `toml:"tools"`
+	Provider   ProviderConfig   `toml:"provider"`
+	Retry      RetryConfig      `toml:"retry"`
+	Compaction CompactionConfig `toml:"compaction"`
+	Cache      CacheConfig      `toml:"cache"`
+	// Prices maps model names to what they cost, for the usage display
+	Prices map[string]usage.Price `toml:"prices"`
+	// Generation maps model names to the generation parameters they start with
+	Generation map[string]params.Params `toml:"generation"`
+}
+
+type ThinkingConfig struct {
+	// Level is off, low, medium, high or dynamic. If it isn't set, Enabled
+	// selects dynamic thinking.
+	Level   string `toml:"level"`
+	Enabled bool   `toml:"enabled"`
+	Show    bool   `toml:"show"`
+}
+
+type ToolsConfig struct {
+	// Timeouts overrides the default timeout of individual tools, keyed by
+	// tool name, e.g. glob_search = "2m"
+	Timeouts map[string]time.Duration `toml:"timeouts"`
+}
+
+type ProviderConfig struct {
+	// Type selects the backend: "gemini" (the default) for the Gemini API,
+	// "vertexai" for Gemini on Vertex AI, or "openai" for any server with an
+	// OpenAI-compatible chat completions API, such as Ollama or llama.cpp
+	Type   string   `toml:"type"`
+	Models []string `toml:"models"` // Models to cycle through; required for "openai"
+
+	// Vertex AI
+	Project         string `toml:"project"`
+	Location        string `toml:"location"`
+	CredentialsFile string `toml:"credentials_file"` // Service account key; Application Default Credentials if empty
+
+	// OpenAI-compatible servers
+	BaseURL   string `toml:"base_url"`
+	APIKeyEnv string `toml:"api_key_env"` // Environment variable holding the API key, if the server needs one
+}
+
+type RetryConfig struct {
+	// MaxAttempts limits how often a request that fails with a rate limit
+	// or server error is tried, including the first attempt
+	MaxAttempts int `toml:"max_attempts"`
+	// FallbackModel, if set, replaces the current model once a request has
+	// failed FallbackAfter times in a row
+	FallbackModel string `toml:"fallback_model"`
+	FallbackAfter int    `toml:"fallback_after"`
+}
+
+type CompactionConfig struct {
+	// Threshold is the share of the context window the history may fill
+	// before it is compacted; 0 turns automatic compaction off
+	Threshold float64 `toml:"threshold"`
+	// ContextWindow overrides the model's context size, in tokens. A smaller
+	// window compacts sooner, which keeps long sessions cheaper.
+	ContextWindow int `toml:"context_window"`
+	// KeepTurns is how many of the latest turns are kept verbatim
+	KeepTurns int `toml:"keep_turns"`
+}
+
+type CacheConfig struct {
+	// Enabled keeps the system prompt, tool declarations and pinned files in
+	// a Gemini context cache instead of sending them with every request. Off
+	// by default, since Google charges for the cache's storage.
+	Enabled bool          `toml:"enabled"`
+	TTL     time.Duration `toml:"ttl"`
+	// Pinned lists files, relative to the project, that are sent ahead of the
+	// conversation in every request
+	Pinned []string `toml:"pinned"`
+}
+
+// DefaultConfig returns the settings used when there is no config file
+func DefaultConfig() *Config {
+	return &Config{
+		Model: "gemini-2.0-flash",
+		Thinking: ThinkingConfig{
+			Enabled: false,
+			Show:    true,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:   5,
+			FallbackAfter: 2,
+		},
+		Compaction: CompactionConfig{
+			Threshold: 0.8,
+			KeepTurns: 2,
+		},
+		Cache: CacheConfig{
+			TTL: 10 * time.Minute,
+		},
+	}
+}
+
+// Dir returns the configuration directory, following the XDG Base Directory spec
+func Dir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "gemini-tui")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gemini-tui")
+}
+
+// Path returns the location of config.toml
+func Path() string {
+	return filepath.Join(Dir(), "config.toml")
+}
+
+// Load reads config.toml, returning the defaults if it doesn't exist
+func Load() (*Config, error) {
+	cfg := DefaultConfig()
+
+	path := Path()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return cfg, nil // Return defaults if no config file
+	}
+
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,248 @@
+// Package instructions loads the instruction files (GEMINI.md, AGENTS.md)
+// that tell the agent about a user's or project's conventions, so they can be
+// added to the system prompt.
+package instructions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/haljac/gemini-tui/tools"
+)
+
+// FileNames are the instruction files looked for in each directory, in the
+// order they are merged
+var FileNames = []string{"GEMINI.md", "AGENTS.md"}
+
+// maxImportDepth limits how deeply @import lines may nest
+const maxImportDepth = 5
+
+// maxNestedDepth limits how far below the working directory nested
+// instruction files are looked for
+const maxNestedDepth = 8
+
+// skipDirs are never searched for nested instruction files
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// Scope says where an instruction file was found
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"    // The user's config directory; applies everywhere
+	ScopeProject Scope = "project" // The repository root or a directory above the working directory
+	ScopeNested  Scope = "nested"  // A directory below the working directory; applies to files there
+)
+
+// File is a loaded instruction file, with its imports expanded
+type File struct {
+	Path    string // Absolute path
+	Rel     string // Path relative to the working directory, or Path for user files
+	Scope   Scope
+	Content string
+	Imports []string // Files pulled in by @import lines, in the order they were read
+}
+
+// Load finds the instruction files in userDir, in every directory from the
+// repository root down to the executor's working directory, and in
+// directories below it that aren't gitignored. They are returned from the
+// most general to the most specific, which is the order they are merged in.
+// An import that can't be read is noted in place of its contents.
+func Load(userDir string, executor *tools.Executor) ([]File, error) {
+	workingDir := executor.WorkingDir()
+	var files []File
+	add := func(dir string, scope Scope) error {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			file, err := load(path, scope, executor)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+		return nil
+	}
+
+	if userDir != "" {
+		if err := add(userDir, ScopeUser); err != nil {
+			return nil, err
+		}
+	}
+
+	// From the repository root down to the working directory
+	root := repoRoot(workingDir)
+	dirs := []string{workingDir}
+	for dir := workingDir; dir != root; {
+		dir = filepath.Dir(dir)
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		if err := add(dir, ScopeProject); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nested directories, in lexical order
+	err := executor.WalkProject(context.Background(), func(path, rel string, d fs.DirEntry) error {
+		if !d.IsDir() || rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()] || strings.Count(rel, "/") >= maxNestedDepth {
+			return filepath.SkipDir
+		}
+		return add(path, ScopeNested)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// repoRoot returns the closest directory at or above dir that contains
+// .git, or dir itself outside a repository
+func repoRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+func load(path string, scope Scope, executor *tools.Executor) (File, error) {
+	file := File{Path: path, Rel: path, Scope: scope}
+	// Only the user's own file may import from anywhere; project files are
+	// untrusted and may only import files inside the working directory
+	check := executor.ResolvePath
+	if scope == ScopeUser {
+		check = filepath.EvalSymlinks
+	} else if rel, err := filepath.Rel(executor.WorkingDir(), path); err == nil {
+		file.Rel = rel
+	}
+	content, err := expand(path, check, map[string]bool{}, 0, &file.Imports)
+	if err != nil {
+		return File{}, err
+	}
+	file.Content = content
+	return file, nil
+}
+
+// expand reads path and replaces each import line with the imported file's
+// contents. Relative paths are resolved against the directory of the file
+// containing the import, and "~/" against the home directory. check returns
+// the real path of a target, or an error if it may not be imported.
+func expand(path string, check func(string) (string, error), seen map[string]bool, depth int, imports *[]string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading instructions: %w", err)
+	}
+	seen[path] = true
+	defer delete(seen, path)
+
+	lines := strings.Split(string(data), "\n")
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		target, explicit, ok := importTarget(trimmed)
+		if inCode || !ok {
+			continue
+		}
+		target = resolve(target, filepath.Dir(path))
+		real, err := check(target)
+		if err == nil {
+			_, err = os.Stat(real)
+		}
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+			// Probably not meant as an import; leave the line alone
+		case err != nil:
+			lines[i] = fmt.Sprintf("(could not @import %s: %v)", target, err)
+		case seen[real]:
+			lines[i] = fmt.Sprintf("(skipped @import of %s: it imports itself)", target)
+		case depth >= maxImportDepth:
+			lines[i] = fmt.Sprintf("(skipped @import of %s: imports nested too deeply)", target)
+		default:
+			n := len(*imports)
+			*imports = append(*imports, real)
+			imported, err := expand(real, check, seen, depth+1, imports)
+			if err != nil {
+				*imports = (*imports)[:n]
+				lines[i] = fmt.Sprintf("(could not @import %s: %v)", target, err)
+				continue
+			}
+			lines[i] = strings.TrimRight(imported, "\n")
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// importTarget returns the path of an import line, written "@import path"
+// or "@path". In the short form the path must contain a "/" or ".", so that
+// an @mention on a line of its own isn't taken for an import.
+func importTarget(line string) (target string, explicit, ok bool) {
+	target, ok = strings.CutPrefix(line, "@")
+	if !ok {
+		return "", false, false
+	}
+	if rest, ok := strings.CutPrefix(target, "import "); ok {
+		target = strings.TrimSpace(rest)
+		explicit = true
+	}
+	if target == "" || strings.ContainsAny(target, " \t") {
+		return "", false, false
+	}
+	if !explicit && !strings.ContainsAny(target, "/.") {
+		return "", false, false
+	}
+	return target, explicit, true
+}
+
+func resolve(target, dir string) string {
+	if rest, ok := strings.CutPrefix(target, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(target) {
+		return filepath.Clean(target)
+	}
+	return filepath.Join(dir, target)
+}
+
+// Prompt merges files into a section of the system prompt. Later files are
+// more specific and take precedence where they disagree.
+func Prompt(files []File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Project Instructions\n\n")
+	sb.WriteString("The user and the project provided these instructions. Follow them. Where they conflict, later sections take precedence over earlier ones, and nested instructions only apply to files under their directory.\n")
+	for _, file := range files {
+		switch file.Scope {
+		case ScopeUser:
+			fmt.Fprintf(&sb, "\n### From the user's %s\n\n", filepath.Base(file.Path))
+		case ScopeNested:
+			fmt.Fprintf(&sb, "\n### From %s (applies to files under %s/)\n\n", file.Rel, filepath.Dir(file.Rel))
+		default:
+			fmt.Fprintf(&sb, "\n### From %s\n\n", file.Rel)
+		}
+		sb.WriteString(strings.TrimSpace(file.Content))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
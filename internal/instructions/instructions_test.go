@@ -0,0 +1,142 @@
+package instructions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/haljac/gemini-tui/tools"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func executor(t *testing.T, dir string) *tools.Executor {
+	t.Helper()
+	e, err := tools.NewExecutor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestLoadOrdersFilesFromGeneralToSpecific(t *testing.T) {
+	user, repo := t.TempDir(), t.TempDir()
+	writeFiles(t, user, map[string]string{"GEMINI.md": "Answer tersely."})
+	writeFiles(t, repo, map[string]string{
+		".git/HEAD":           "ref: refs/heads/main",
+		"AGENTS.md":           "Run `make test` before finishing.",
+		"cmd/GEMINI.md":       "Flags use the flag package.",
+		"cmd/app/AGENTS.md":   "Keep main small.",
+		"api/AGENTS.md":       "Never edit generated files.",
+		".hidden/GEMINI.md":   "ignored",
+		"cmd/.gitignore":      "build/\n",
+		"cmd/build/GEMINI.md": "ignored",
+		"node_modules/x.md":   "ignored",
+		"cmd/app/main.go":     "package main",
+		"docs/notes/GEMINI":   "not an instruction file",
+		"cmd/app/sub/README":  "",
+	})
+
+	files, err := Load(user, executor(t, filepath.Join(repo, "cmd")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, string(f.Scope)+":"+f.Rel)
+	}
+	want := []string{
+		"user:" + filepath.Join(user, "GEMINI.md"),
+		"project:" + filepath.Join("..", "AGENTS.md"),
+		"project:GEMINI.md",
+		"nested:" + filepath.Join("app", "AGENTS.md"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("files:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	prompt := Prompt(files)
+	if !strings.Contains(prompt, "Answer tersely.") || strings.Index(prompt, "make test") > strings.Index(prompt, "Keep main small.") {
+		t.Fatalf("unexpected prompt:\n%s", prompt)
+	}
+}
+
+func TestImportsAreExpanded(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"GEMINI.md":      "# Rules\n@docs/style.md\n@import docs/missing.md\nEmail @alice about releases.\n@bob\n@later.md\n```\n@not/an/import\n```",
+		"docs/style.md":  "Use tabs.\n@../GEMINI.md\n@deeper.md",
+		"docs/deeper.md": "No globals.",
+	})
+
+	files, err := Load("", executor(t, repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files", len(files))
+	}
+	content := files[0].Content
+	for _, want := range []string{
+		"Use tabs.",
+		"No globals.",
+		"it imports itself",
+		"could not @import " + filepath.Join(repo, "docs", "missing.md"),
+		"Email @alice about releases.",
+		"\n@bob\n",
+		"\n@later.md\n",
+		"@not/an/import",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content is missing %q:\n%s", want, content)
+		}
+	}
+	if len(files[0].Imports) != 2 {
+		t.Errorf("imports = %v", files[0].Imports)
+	}
+}
+
+func TestProjectImportsStayInTheWorkingDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"secret/creds":           "hunter2",
+		"user/GEMINI.md":         "@../secret/creds",
+		"repo/GEMINI.md":         "@../secret/creds\n@import /etc/hostname\n@linked/creds",
+		"repo/nested/AGENTS.md":  "@../../secret/creds",
+		"repo/nested/README.txt": "",
+	})
+	if err := os.Symlink(filepath.Join(root, "secret"), filepath.Join(root, "repo", "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Load(filepath.Join(root, "user"), executor(t, filepath.Join(root, "repo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("got %d files", len(files))
+	}
+	if !strings.Contains(files[0].Content, "hunter2") {
+		t.Errorf("the user's file should import from anywhere:\n%s", files[0].Content)
+	}
+	for _, file := range files[1:] {
+		if strings.Contains(file.Content, "hunter2") || strings.Count(file.Content, "outside allowed directory") != strings.Count(file.Content, "@import") {
+			t.Errorf("%s imported a file outside the project:\n%s", file.Rel, file.Content)
+		}
+		if len(file.Imports) != 0 {
+			t.Errorf("%s: imports = %v", file.Rel, file.Imports)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package params holds the generation parameters, such as temperature and
+// seed, that can be set per model in the config and per session with /set
+package params
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Names lists the parameters /set accepts
+var Names = []string{"temperature", "top_p", "top_k", "max_output_tokens", "stop", "seed"}
+
+// Params are generation parameters. Unset ones (nil) are left to the model's
+// defaults.
+type Params struct {
+	Temperature     *float32 `toml:"temperature"`
+	TopP            *float32 `toml:"top_p"`
+	TopK            *float32 `toml:"top_k"`
+	MaxOutputTokens *int32   `toml:"max_output_tokens"`
+	StopSequences   []string `toml:"stop"`
+	Seed            *int32   `toml:"seed"`
+}
+
+// Merge returns p with the parameters set in o replacing its own
+func (p Params) Merge(o Params) Params {
+	if o.Temperature != nil {
+		p.Temperature = o.Temperature
+	}
+	if o.TopP != nil {
+		p.TopP = o.TopP
+	}
+	if o.TopK != nil {
+		p.TopK = o.TopK
+	}
+	if o.MaxOutputTokens != nil {
+		p.MaxOutputTokens = o.MaxOutputTokens
+	}
+	if o.StopSequences != nil {
+		p.StopSequences = o.StopSequences
+	}
+	if o.Seed != nil {
+		p.Seed = o.Seed
+	}
+	return p
+}
+
+// Set parses values for the parameter called name. Only stop takes more
+// than one value; each is a stop sequence.
+func (p *Params) Set(name string, values []string) error {
+	if name != "stop" && len(values) != 1 {
+		return fmt.Errorf("usage: /set %s <value>", name)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("usage: /set stop <sequence>...")
+	}
+	value := values[0]
+
+	switch name {
+	case "temperature":
+		f, err := parseFloat(name, value, 0, 2)
+		if err != nil {
+			return err
+		}
+		p.Temperature = &f
+	case "top_p":
+		f, err := parseFloat(name, value, 0, 1)
+		if err != nil {
+			return err
+		}
+		p.TopP = &f
+	case "top_k":
+		n, err := parseInt(name, value, 1)
+		if err != nil {
+			return err
+		}
+		k := float32(n)
+		p.TopK = &k
+	case "max_output_tokens":
+		n, err := parseInt(name, value, 1)
+		if err != nil {
+			return err
+		}
+		p.MaxOutputTokens = &n
+	case "stop":
+		p.StopSequences = values
+	case "seed":
+		n, err := parseInt(name, value, 0)
+		if err != nil {
+			return err
+		}
+		p.Seed = &n
+	default:
+		return unknown(name)
+	}
+	return nil
+}
+
+// Unset clears the parameter called name
+func (p *Params) Unset(name string) error {
+	switch name {
+	case "temperature":
+		p.Temperature = nil
+	case "top_p":
+		p.TopP = nil
+	case "top_k":
+		p.TopK = nil
+	case "max_output_tokens":
+		p.MaxOutputTokens = nil
+	case "stop":
+		p.StopSequences = nil
+	case "seed":
+		p.Seed = nil
+	default:
+		return unknown(name)
+	}
+	return nil
+}
+
+func unknown(name string) error {
+	return fmt.Errorf("unknown parameter %q (use %s)", name, strings.Join(Names, ", "))
+}
+
+func parseFloat(name, value string, lo, hi float64) (float32, error) {
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil || f < lo || f > hi {
+		return 0, fmt.Errorf("%s must be a number from %g to %g", name, lo, hi)
+	}
+	return float32(f), nil
+}
+
+func parseInt(name, value string, lo int64) (int32, error) {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < lo {
+		return 0, fmt.Errorf("%s must be a whole number of at least %d", name, lo)
+	}
+	return int32(n), nil
+}
+
+// Apply sets the parameters in config
+func (p Params) Apply(config *genai.GenerateContentConfig) {
+	config.Temperature = p.Temperature
+	config.TopP = p.TopP
+	config.TopK = p.TopK
+	if p.MaxOutputTokens != nil {
+		config.MaxOutputTokens = *p.MaxOutputTokens
+	}
+	config.StopSequences = p.StopSequences
+	config.Seed = p.Seed
+}
+
+// String lists the parameters that are set, e.g. "temperature 0.2, seed 42",
+// or returns "" if none are
+func (p Params) String() string {
+	var parts []string
+	if p.Temperature != nil {
+		parts = append(parts, "temperature "+formatFloat(*p.Temperature))
+	}
+	if p.TopP != nil {
+		parts = append(parts, "top_p "+formatFloat(*p.TopP))
+	}
+	if p.TopK != nil {
+		parts = append(parts, "top_k "+formatFloat(*p.TopK))
+	}
+	if p.MaxOutputTokens != nil {
+		parts = append(parts, fmt.Sprintf("max_output_tokens %d", *p.MaxOutputTokens))
+	}
+	if p.StopSequences != nil {
+		parts = append(parts, fmt.Sprintf("stop %q", p.StopSequences))
+	}
+	if p.Seed != nil {
+		parts = append(parts, fmt.Sprintf("seed %d", *p.Seed))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
@@ -0,0 +1,70 @@
+package params
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestSetMergeAndApply(t *testing.T) {
+	var modelDefaults Params
+	if err := modelDefaults.Set("temperature", []string{"0.7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := modelDefaults.Set("top_k", []string{"40"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var session Params
+	for name, values := range map[string][]string{
+		"temperature":       {"0.2"},
+		"seed":              {"42"},
+		"stop":              {"END", "###"},
+		"max_output_tokens": {"2048"},
+	} {
+		if err := session.Set(name, values); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	effective := modelDefaults.Merge(session)
+	if got, want := effective.String(), `temperature 0.2, top_k 40, max_output_tokens 2048, stop ["END" "###"], seed 42`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	config := &genai.GenerateContentConfig{}
+	effective.Apply(config)
+	if *config.Temperature != 0.2 || *config.TopK != 40 || config.MaxOutputTokens != 2048 || *config.Seed != 42 || len(config.StopSequences) != 2 || config.TopP != nil {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+
+	if err := session.Unset("temperature"); err != nil {
+		t.Fatal(err)
+	}
+	if got := *modelDefaults.Merge(session).Temperature; got != 0.7 {
+		t.Fatalf("temperature after unset = %v, want the model default", got)
+	}
+}
+
+func TestSetRejectsInvalidValues(t *testing.T) {
+	var p Params
+	for _, tt := range []struct {
+		name   string
+		values []string
+	}{
+		{"temperature", []string{"3"}},
+		{"top_p", []string{"-0.1"}},
+		{"top_k", []string{"1.5"}},
+		{"seed", []string{"x"}},
+		{"temperature", []string{"0.1", "0.2"}},
+		{"stop", nil},
+		{"colour", []string{"blue"}},
+	} {
+		if err := p.Set(tt.name, tt.values); err == nil {
+			t.Errorf("%s %v: expected an error", tt.name, tt.values)
+		}
+	}
+	if p.String() != "" {
+		t.Fatalf("invalid values were stored: %s", p)
+	}
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Prefix is the part of every request that stays the same from one request
+// to the next: the system instruction, the tool declarations and any
+// contents sent ahead of the conversation, such as pinned files
+type Prefix struct {
+	System   *genai.Content
+	Tools    []*genai.Tool
+	Contents []*genai.Content
+}
+
+// key identifies the prefix, so a cache can tell when it has changed. It
+// also returns a rough count of the prefix's tokens.
+func (p *Prefix) key() (string, int, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), len(data) / 4, nil
+}
+
+// minCacheTokens is the smallest prefix Gemini will cache for model. Asking
+// for a smaller one fails, and every failed attempt is a wasted request.
+func minCacheTokens(model string) int {
+	if strings.HasPrefix(model, "gemini-2.5-pro") || strings.HasPrefix(model, "gemini-3-pro") {
+		return 4096
+	}
+	return 1024
+}
+
+// Cacher is implemented by providers that can store a request prefix on the
+// server, so it isn't sent with every request and is billed at a lower rate
+type Cacher interface {
+	// CachePrefix returns the name of a cache holding prefix for model,
+	// creating it or renewing its TTL as needed. Requests that use the
+	// cache leave out the prefix and set CachedContent to the name.
+	CachePrefix(ctx context.Context, model string, prefix *Prefix, ttl time.Duration) (string, error)
+	// ReleaseCaches deletes every cache created so far
+	ReleaseCaches(ctx context.Context)
+}
+
+// geminiCache is the cache kept for one model
+type geminiCache struct {
+	key     string // Prefix the cache holds
+	name    string
+	expires time.Time
+	err     error // Why the cache couldn't be created; retried once expires passes
+}
+
+func (g *Gemini) CachePrefix(ctx context.Context, model string, prefix *Prefix, ttl time.Duration) (string, error) {
+	key, tokens, err := prefix.key()
+	if err != nil {
+		return "", err
+	}
+	if least := minCacheTokens(model); tokens < least {
+		return "", fmt.Errorf("prefix of about %d tokens is below the %d-token minimum for caching with %s", tokens, least, model)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	cache := g.caches[model]
+	if cache != nil && cache.key == key && now.Before(cache.expires) {
+		if cache.err != nil {
+			return "", cache.err
+		}
+		// Renew the TTL once half of it has passed
+		if cache.expires.Sub(now) > ttl/2 {
+			return cache.name, nil
+		}
+		updated, err := g.client.Caches.Update(ctx, cache.name, &genai.UpdateCachedContentConfig{TTL: ttl})
+		if err == nil {
+			cache.expires = updated.ExpireTime
+			return cache.name, nil
+		}
+	}
+
+	// The prefix changed or the cache expired: replace it
+	if cache != nil && cache.name != "" {
+		g.client.Caches.Delete(ctx, cache.name, nil)
+	}
+	created, err := g.client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		TTL:               ttl,
+		DisplayName:       "gemini-tui",
+		SystemInstruction: prefix.System,
+		Tools:             prefix.Tools,
+		Contents:          prefix.Contents,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			delete(g.caches, model)
+			return "", err
+		}
+		// Send the prefix inline until the TTL has passed rather than
+		// retrying with every request
+		g.caches[model] = &geminiCache{key: key, expires: now.Add(ttl), err: err}
+		return "", err
+	}
+	g.caches[model] = &geminiCache{key: key, name: created.Name, expires: created.ExpireTime}
+	return created.Name, nil
+}
+
+func (g *Gemini) ReleaseCaches(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for model, cache := range g.caches {
+		if cache.name != "" {
+			g.client.Caches.Delete(ctx, cache.name, nil)
+		}
+		delete(g.caches, model)
+	}
+}
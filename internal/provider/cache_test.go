@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestGeminiCachePrefixReusesAndReplaces(t *testing.T) {
+	var requests []string
+	created := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPost {
+			created++
+		}
+		expires := time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `{"name":"cachedContents/c%d","expireTime":%q}`, created, expires)
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGemini(client, []string{"gemini-2.5-flash"})
+	ctx := context.Background()
+	prefix := &Prefix{System: genai.NewContentFromText("be brief", "user")}
+
+	// Too small to cache: no cache is created
+	if _, err := g.CachePrefix(ctx, "gemini-2.5-flash", prefix, 10*time.Minute); err == nil || len(requests) != 0 {
+		t.Fatalf("got %v after %d requests, want an error before any", err, len(requests))
+	}
+
+	prefix.System = genai.NewContentFromText(strings.Repeat("Be brief. ", 500), "user")
+
+	for range 2 {
+		name, err := g.CachePrefix(ctx, "gemini-2.5-flash", prefix, 10*time.Minute)
+		if err != nil || name != "cachedContents/c1" {
+			t.Fatalf("got %q, %v", name, err)
+		}
+	}
+
+	// A changed prefix replaces the cache
+	prefix.Contents = []*genai.Content{genai.NewContentFromText("pinned file", "user")}
+	name, err := g.CachePrefix(ctx, "gemini-2.5-flash", prefix, 10*time.Minute)
+	if err != nil || name != "cachedContents/c2" {
+		t.Fatalf("got %q, %v", name, err)
+	}
+
+	// Less than half the TTL left: renew it
+	name, err = g.CachePrefix(ctx, "gemini-2.5-flash", prefix, time.Hour)
+	if err != nil || name != "cachedContents/c2" {
+		t.Fatalf("got %q, %v", name, err)
+	}
+
+	g.ReleaseCaches(ctx)
+
+	want := []string{
+		"POST /v1beta/cachedContents",
+		"DELETE /v1beta/cachedContents/c1",
+		"POST /v1beta/cachedContents",
+		"PATCH /v1beta/cachedContents/c2",
+		"DELETE /v1beta/cachedContents/c2",
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Fatalf("requests:\n got %v\nwant %v", requests, want)
+	}
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// Gemini is the Provider for Google's hosted Gemini API, whether reached
+// directly or through Vertex AI. It supports context caching.
+type Gemini struct {
+	client *genai.Client
+	models []string
+
+	mu     sync.Mutex
+	caches map[string]*geminiCache // Keyed by model
+}
+
+// NewGemini wraps a genai client. models lists the selectable models, the
+// default first.
+func NewGemini(client *genai.Client, models []string) *Gemini {
+	return &Gemini{client: client, models: models, caches: make(map[string]*geminiCache)}
+}
+
+func (g *Gemini) Name() string { return "gemini" }
+
+func (g *Gemini) Models() []string { return g.models }
+
+func (g *Gemini) GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return g.client.Models.GenerateContentStream(ctx, model, contents, config)
+}
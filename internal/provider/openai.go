@@ -0,0 +1,449 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// DefaultOpenAIBaseURL is Ollama's OpenAI-compatible endpoint
+const DefaultOpenAIBaseURL = "http://localhost:11434/v1"
+
+// OpenAI is the Provider for servers implementing the OpenAI chat
+// completions API, such as Ollama, llama.cpp's llama-server and vLLM
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	models  []string
+	client  *http.Client
+}
+
+// NewOpenAI creates a provider for the server at baseURL (e.g.
+// "http://localhost:11434/v1"). apiKey may be empty for local servers.
+func NewOpenAI(baseURL, apiKey string, models []string) *OpenAI {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	return &OpenAI{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		models:  models,
+		client:  http.DefaultClient,
+	}
+}
+
+func (p *OpenAI) Name() string { return "openai" }
+
+func (p *OpenAI) Models() []string { return p.models }
+
+// Chat completions wire format
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []chatTool    `json:"tools,omitempty"`
+	Stream   bool          `json:"stream"`
+
+	// Generation parameters; top_k isn't part of the OpenAI API but llama.cpp
+	// and vLLM accept it
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	TopK        *float32 `json:"top_k,omitempty"`
+	MaxTokens   int32    `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int32   `json:"seed,omitempty"`
+
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function chatFunctionCall `json:"function"`
+}
+
+// chatToolCallDelta is a fragment of a tool call in a streamed response
+type chatToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id"`
+	Function chatFunctionCall `json:"function"`
+}
+
+type chatFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string              `json:"content"`
+			ReasoningContent string              `json:"reasoning_content"` // llama.cpp, vLLM
+			Reasoning        string              `json:"reasoning"`         // Ollama
+			ToolCalls        []chatToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"` // Sent with the last chunk
+	Error *chatError `json:"error"`
+}
+
+type chatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+type chatError struct {
+	Message string `json:"message"`
+}
+
+func (p *OpenAI) GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		request := chatRequest{
+			Model:    model,
+			Messages: chatMessages(contents, config),
+			Tools:    chatTools(config),
+			Stream:   true,
+		}
+		request.StreamOptions.IncludeUsage = true
+		if config != nil {
+			request.Temperature = config.Temperature
+			request.TopP = config.TopP
+			request.TopK = config.TopK
+			request.MaxTokens = config.MaxOutputTokens
+			request.Stop = config.StopSequences
+			request.Seed = config.Seed
+		}
+		body, err := json.Marshal(request)
+		if err != nil {
+			yield(nil, fmt.Errorf("failed to encode request: %w", err))
+			return
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		if p.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			var apiErr struct {
+				Error *chatError `json:"error"`
+			}
+			message := strings.TrimSpace(string(data))
+			if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != nil {
+				message = apiErr.Error.Message
+			}
+			statusErr := &StatusError{Code: resp.StatusCode, Status: resp.Status, Message: message}
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				statusErr.RetryAfter = time.Duration(secs) * time.Second
+			}
+			yield(nil, statusErr)
+			return
+		}
+
+		// Tool call arguments arrive in fragments, keyed by index
+		var calls []*chatToolCall
+		var arguments []string
+		var usage *genai.GenerateContentResponseUsageMetadata
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk chatChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				yield(nil, fmt.Errorf("invalid stream chunk: %w", err))
+				return
+			}
+			if chunk.Error != nil {
+				yield(nil, fmt.Errorf("server error: %s", chunk.Error.Message))
+				return
+			}
+			if u := chunk.Usage; u != nil {
+				// Reasoning is part of the completion here but counted apart by Gemini
+				reasoning := u.CompletionTokensDetails.ReasoningTokens
+				usage = &genai.GenerateContentResponseUsageMetadata{
+					PromptTokenCount:        int32(u.PromptTokens),
+					CachedContentTokenCount: int32(u.PromptTokensDetails.CachedTokens),
+					ThoughtsTokenCount:      int32(reasoning),
+					CandidatesTokenCount:    int32(u.CompletionTokens - reasoning),
+					TotalTokenCount:         int32(u.PromptTokens + u.CompletionTokens),
+				}
+			}
+
+			for _, choice := range chunk.Choices {
+				delta := choice.Delta
+				var parts []*genai.Part
+				if reasoning := delta.ReasoningContent + delta.Reasoning; reasoning != "" {
+					parts = append(parts, &genai.Part{Text: reasoning, Thought: true})
+				}
+				if delta.Content != "" {
+					parts = append(parts, &genai.Part{Text: delta.Content})
+				}
+				for _, tc := range delta.ToolCalls {
+					for len(calls) <= tc.Index {
+						calls = append(calls, &chatToolCall{})
+						arguments = append(arguments, "")
+					}
+					call := calls[tc.Index]
+					if tc.ID != "" {
+						call.ID = tc.ID
+					}
+					if tc.Function.Name != "" {
+						call.Function.Name = tc.Function.Name
+					}
+					arguments[tc.Index] += tc.Function.Arguments
+				}
+				if len(parts) > 0 && !yield(modelResponse(parts), nil) {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var parts []*genai.Part
+		for i, call := range calls {
+			args := map[string]any{}
+			if raw := strings.TrimSpace(arguments[i]); raw != "" {
+				if err := json.Unmarshal([]byte(raw), &args); err != nil {
+					yield(nil, fmt.Errorf("model sent invalid arguments for %s: %w", call.Function.Name, err))
+					return
+				}
+			}
+			parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+				ID:   call.ID,
+				Name: call.Function.Name,
+				Args: args,
+			}})
+		}
+		final := modelResponse(parts)
+		if len(parts) == 0 {
+			final.Candidates = nil
+		}
+		final.UsageMetadata = usage
+		if len(parts) > 0 || usage != nil {
+			yield(final, nil)
+		}
+	}
+}
+
+// modelResponse wraps parts in a single-candidate response
+func modelResponse(parts []*genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			Content: &genai.Content{Role: "model", Parts: parts},
+		}},
+	}
+}
+
+// chatMessages converts the system instruction and conversation to chat
+// messages. Function calls without an ID are given one, and responses
+// without an ID are matched to the earliest unanswered call of that name.
+func chatMessages(contents []*genai.Content, config *genai.GenerateContentConfig) []chatMessage {
+	var messages []chatMessage
+	if config != nil && config.SystemInstruction != nil {
+		if system := partsText(config.SystemInstruction.Parts); system != "" {
+			messages = append(messages, chatMessage{Role: "system", Content: system})
+		}
+	}
+
+	unanswered := make(map[string][]string) // Call IDs by function name
+	for ci, content := range contents {
+		if content.Role == "model" {
+			msg := chatMessage{Role: "assistant", Content: partsText(content.Parts)}
+			for i, part := range content.Parts {
+				if part.FunctionCall == nil {
+					continue
+				}
+				call := part.FunctionCall
+				id := call.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", ci, i)
+				}
+				unanswered[call.Name] = append(unanswered[call.Name], id)
+				args, err := json.Marshal(call.Args)
+				if err != nil || call.Args == nil {
+					args = []byte("{}")
+				}
+				msg.ToolCalls = append(msg.ToolCalls, chatToolCall{
+					ID:       id,
+					Type:     "function",
+					Function: chatFunctionCall{Name: call.Name, Arguments: string(args)},
+				})
+			}
+			messages = append(messages, msg)
+			continue
+		}
+
+		for _, part := range content.Parts {
+			resp := part.FunctionResponse
+			if resp == nil {
+				continue
+			}
+			id := resp.ID
+			if ids := unanswered[resp.Name]; id == "" && len(ids) > 0 {
+				id, unanswered[resp.Name] = ids[0], ids[1:]
+			}
+			result, err := json.Marshal(resp.Response)
+			if err != nil {
+				result = []byte(fmt.Sprintf(`{"error": %q}`, err.Error()))
+			}
+			messages = append(messages, chatMessage{Role: "tool", Content: string(result), ToolCallID: id})
+		}
+		if text := partsText(content.Parts); text != "" {
+			messages = append(messages, chatMessage{Role: "user", Content: text})
+		}
+	}
+	return messages
+}
+
+// partsText joins the text of the parts that aren't thoughts
+func partsText(parts []*genai.Part) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// chatTools converts the function declarations in config to chat tools
+func chatTools(config *genai.GenerateContentConfig) []chatTool {
+	if config == nil {
+		return nil
+	}
+	var tools []chatTool
+	for _, tool := range config.Tools {
+		for _, decl := range tool.FunctionDeclarations {
+			params := map[string]any{"type": "object", "properties": map[string]any{}}
+			if decl.Parameters != nil {
+				params = jsonSchema(decl.Parameters)
+			}
+			tools = append(tools, chatTool{
+				Type: "function",
+				Function: chatFunction{
+					Name:        decl.Name,
+					Description: decl.Description,
+					Parameters:  params,
+				},
+			})
+		}
+	}
+	return tools
+}
+
+// jsonSchema converts a genai schema to standard JSON Schema
+func jsonSchema(s *genai.Schema) map[string]any {
+	out := map[string]any{}
+	if s.Type != "" {
+		typ := strings.ToLower(string(s.Type))
+		if s.Nullable != nil && *s.Nullable {
+			out["type"] = []string{typ, "null"}
+		} else {
+			out["type"] = typ
+		}
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Minimum != nil {
+		out["minimum"] = *s.Minimum
+	}
+	if s.Maximum != nil {
+		out["maximum"] = *s.Maximum
+	}
+	if s.MinItems != nil {
+		out["minItems"] = *s.MinItems
+	}
+	if s.MaxItems != nil {
+		out["maxItems"] = *s.MaxItems
+	}
+	if s.Items != nil {
+		out["items"] = jsonSchema(s.Items)
+	}
+	if len(s.AnyOf) > 0 {
+		anyOf := make([]any, len(s.AnyOf))
+		for i, sub := range s.AnyOf {
+			anyOf[i] = jsonSchema(sub)
+		}
+		out["anyOf"] = anyOf
+	}
+	if s.Type == genai.TypeObject {
+		props := map[string]any{}
+		for name, sub := range s.Properties {
+			props[name] = jsonSchema(sub)
+		}
+		out["properties"] = props
+		if len(s.Required) > 0 {
+			out["required"] = s.Required
+		}
+	}
+	return out
+}
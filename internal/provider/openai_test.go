@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestChatMessagesPairsToolCallsWithResults(t *testing.T) {
+	contents := []*genai.Content{
+		{Role: "user", Parts: []*genai.Part{{Text: "what's in main.go?"}}},
+		{Role: "model", Parts: []*genai.Part{
+			{Text: "Let me look.", Thought: true},
+			{FunctionCall: &genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "main.go"}}},
+			{FunctionCall: &genai.FunctionCall{ID: "abc", Name: "read_file", Args: map[string]any{"path": "go.mod"}}},
+		}},
+		{Role: "user", Parts: []*genai.Part{
+			{FunctionResponse: &genai.FunctionResponse{Name: "read_file", Response: map[string]any{"content": "package main"}}},
+			{FunctionResponse: &genai.FunctionResponse{ID: "abc", Name: "read_file", Response: map[string]any{"content": "module x"}}},
+			{Text: "only the imports, please"},
+		}},
+	}
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: "be brief"}}},
+	}
+
+	got := chatMessages(contents, config)
+
+	want := []chatMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "what's in main.go?"},
+		{Role: "assistant", ToolCalls: []chatToolCall{
+			{ID: "call_1_1", Type: "function", Function: chatFunctionCall{Name: "read_file", Arguments: `{"path":"main.go"}`}},
+			{ID: "abc", Type: "function", Function: chatFunctionCall{Name: "read_file", Arguments: `{"path":"go.mod"}`}},
+		}},
+		{Role: "tool", ToolCallID: "call_1_1", Content: `{"content":"package main"}`},
+		{Role: "tool", ToolCallID: "abc", Content: `{"content":"module x"}`},
+		{Role: "user", Content: "only the imports, please"},
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Fatalf("messages:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func TestJSONSchemaConvertsTypes(t *testing.T) {
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"paths": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+		},
+		Required: []string{"paths"},
+	}
+
+	got, _ := json.Marshal(jsonSchema(schema))
+	want := `{"properties":{"paths":{"items":{"type":"string"},"type":"array"}},"required":["paths"],"type":"object"}`
+	if string(got) != want {
+		t.Fatalf("schema:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestOpenAIStreamsTextAndToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream || req.Model != "qwen3" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"reasoning_content":"hmm"}}]}`,
+			`{"choices":[{"delta":{"content":"Reading "}}]}`,
+			`{"choices":[{"delta":{"content":"it."}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c1","function":{"name":"read_file","arguments":"{\"pa"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"a.go\"}"}}]}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"completion_tokens_details":{"reasoning_tokens":2}}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewOpenAI(server.URL+"/v1/", "", []string{"qwen3"})
+	var text, thoughts string
+	var calls []*genai.FunctionCall
+	var usage *genai.GenerateContentResponseUsageMetadata
+	for resp, err := range p.GenerateContentStream(context.Background(), "qwen3", []*genai.Content{
+		{Role: "user", Parts: []*genai.Part{{Text: "read a.go"}}},
+	}, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) == 0 {
+			continue
+		}
+		for _, part := range resp.Candidates[0].Content.Parts {
+			switch {
+			case part.Thought:
+				thoughts += part.Text
+			case part.Text != "":
+				text += part.Text
+			}
+		}
+		calls = append(calls, resp.FunctionCalls()...)
+	}
+
+	if thoughts != "hmm" || text != "Reading it." {
+		t.Fatalf("got thoughts %q, text %q", thoughts, text)
+	}
+	if len(calls) != 1 || calls[0].ID != "c1" || calls[0].Name != "read_file" || calls[0].Args["path"] != "a.go" {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+	if usage == nil || usage.PromptTokenCount != 12 || usage.ThoughtsTokenCount != 2 || usage.CandidatesTokenCount != 5 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestOpenAIReportsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"message":"model \"nope\" not found"}}`)
+	}))
+	defer server.Close()
+
+	p := NewOpenAI(server.URL, "", []string{"nope"})
+	for _, err := range p.GenerateContentStream(context.Background(), "nope", nil, nil) {
+		if err == nil || err.Error() != `404 Not Found: model "nope" not found` {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	t.Fatal("expected an error")
+}
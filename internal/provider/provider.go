@@ -0,0 +1,21 @@
+package provider
+
+import (
+	"context"
+	"iter"
+
+	"google.golang.org/genai"
+)
+
+// Provider streams model responses, including function calls, from an LLM
+// backend. Conversations, tool declarations and responses use the genai
+// types throughout, so backends other than Gemini translate to and from them.
+type Provider interface {
+	// Name identifies the backend, e.g. "gemini"
+	Name() string
+	// Models lists the models that can be selected, the default first
+	Models() []string
+	// GenerateContentStream sends the conversation and yields the response
+	// as it arrives. Iteration stops early once ctx is cancelled.
+	GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error]
+}
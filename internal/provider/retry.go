@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const (
+	baseBackoff = time.Second
+	maxBackoff  = time.Minute
+)
+
+// StatusError is an unsuccessful HTTP response from a provider that doesn't
+// have its own error type
+type StatusError struct {
+	Code       int
+	Status     string
+	Message    string
+	RetryAfter time.Duration // From the Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return e.Status + ": " + e.Message
+}
+
+// Retryable reports whether err is a transient failure, such as a rate limit
+// or an overloaded server, that is worth retrying. It also returns how long
+// the server asked the client to wait, or zero if it didn't say.
+func Retryable(err error) (retryAfter time.Duration, ok bool) {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retryInfoDelay(apiErr.Details), retryableStatus(apiErr.Code)
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter, retryableStatus(statusErr.Code)
+	}
+	return 0, false
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case 429, 500, 502, 503, 504:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryInfoDelay finds the delay in a google.rpc.RetryInfo error detail
+func retryInfoDelay(details []map[string]any) time.Duration {
+	for _, detail := range details {
+		if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+			continue
+		}
+		if s, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// Backoff returns how long to wait before retry number attempt, starting at
+// 1. The delay doubles with each attempt up to a minute, and is jittered so
+// that clients don't retry in lockstep. A delay requested by the server
+// takes precedence.
+func Backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := maxBackoff
+	if attempt < 7 {
+		d = min(baseBackoff<<(attempt-1), maxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestRetryableClassifiesErrors(t *testing.T) {
+	quota := genai.APIError{
+		Code:   429,
+		Status: "RESOURCE_EXHAUSTED",
+		Details: []map[string]any{
+			{"@type": "type.googleapis.com/google.rpc.QuotaFailure"},
+			{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "27s"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		delay     time.Duration
+	}{
+		{"gemini quota with hint", fmt.Errorf("stream: %w", quota), true, 27 * time.Second},
+		{"gemini overloaded", genai.APIError{Code: 503}, true, 0},
+		{"gemini bad request", genai.APIError{Code: 400}, false, 0},
+		{"openai rate limit", &StatusError{Code: 429, RetryAfter: 5 * time.Second}, true, 5 * time.Second},
+		{"openai not found", &StatusError{Code: 404}, false, 0},
+		{"other error", errors.New("boom"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := Retryable(tt.err)
+			if ok != tt.retryable || delay != tt.delay {
+				t.Fatalf("Retryable() = %s, %v; want %s, %v", delay, ok, tt.delay, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestBackoffGrowsWithJitter(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 20: time.Minute} {
+		for range 50 {
+			d := Backoff(attempt, 0)
+			if d < want/2 || d > want {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", attempt, d, want/2, want)
+			}
+		}
+	}
+	if d := Backoff(1, 10*time.Second); d != 10*time.Second {
+		t.Fatalf("server hint ignored: got %s", d)
+	}
+}
@@ -0,0 +1,185 @@
+// Package thinking maps thinking levels to what each model supports: a
+// token budget for Gemini 2.5, a thinking level for Gemini 3, and nothing at
+// all for models that don't think.
+package thinking
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Level is how much the model should think before answering
+type Level string
+
+const (
+	Off     Level = "off"
+	Low     Level = "low"
+	Medium  Level = "medium"
+	High    Level = "high"
+	Dynamic Level = "dynamic" // The model decides how much to think
+)
+
+// Levels lists the levels in the order Ctrl+T cycles through them
+var Levels = []Level{Off, Low, Medium, High, Dynamic}
+
+// ParseLevel reads a level name
+func ParseLevel(s string) (Level, error) {
+	for _, level := range Levels {
+		if strings.EqualFold(s, string(level)) {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown thinking level %q (use off, low, medium, high or dynamic)", s)
+}
+
+// Next returns the level after l
+func (l Level) Next() Level {
+	for i, level := range Levels {
+		if level == l {
+			return Levels[(i+1)%len(Levels)]
+		}
+	}
+	return Off
+}
+
+// support describes how a model's thinking is controlled
+type support struct {
+	none       bool // The model doesn't think
+	minBudget  int32
+	maxBudget  int32
+	canDisable bool                  // A budget of 0 turns thinking off
+	levels     []genai.ThinkingLevel // Set for models controlled by level rather than budget, lowest first
+}
+
+// models is matched by prefix, so more specific names come first
+var models = []struct {
+	prefix string
+	support
+}{
+	{"gemini-2.0", support{none: true}},
+	{"gemini-2.5-flash-lite", support{minBudget: 512, maxBudget: 24576, canDisable: true}},
+	{"gemini-2.5-flash", support{minBudget: 1, maxBudget: 24576, canDisable: true}},
+	{"gemini-2.5-pro", support{minBudget: 128, maxBudget: 32768}},
+	{"gemini-3-flash", support{levels: []genai.ThinkingLevel{genai.ThinkingLevelMinimal, genai.ThinkingLevelLow, genai.ThinkingLevelMedium, genai.ThinkingLevelHigh}}},
+	{"gemini-3", support{levels: []genai.ThinkingLevel{genai.ThinkingLevelLow, genai.ThinkingLevelHigh}}},
+}
+
+// otherModels is assumed for models not in the table, such as local ones
+var otherModels = support{minBudget: 1, maxBudget: 24576, canDisable: true}
+
+// Budgets for the levels, before clamping to the model's range
+const (
+	lowBudget    = 1024
+	mediumBudget = 8192
+)
+
+func lookup(model string) support {
+	for _, m := range models {
+		if strings.HasPrefix(model, m.prefix) {
+			return m.support
+		}
+	}
+	return otherModels
+}
+
+// Supported reports whether model can think at all
+func Supported(model string) bool {
+	return !lookup(model).none
+}
+
+// Setting is a level resolved for a particular model
+type Setting struct {
+	Level  Level                 // The level in effect, which may differ from the one asked for
+	Config *genai.ThinkingConfig // nil if the model doesn't think
+	Note   string                // Why Level differs from the level asked for, if it does
+}
+
+// Resolve maps level to model's thinking controls. A level the model can't
+// provide is clamped to the closest one it can.
+func Resolve(model string, level Level) Setting {
+	s := lookup(model)
+	if s.none {
+		setting := Setting{Level: Off}
+		if level != Off {
+			setting.Note = model + " doesn't support thinking"
+		}
+		return setting
+	}
+
+	if s.levels != nil {
+		return resolveLevel(model, level, s.levels)
+	}
+
+	setting := Setting{Level: level, Config: &genai.ThinkingConfig{IncludeThoughts: level != Off}}
+	var budget int32
+	switch level {
+	case Off:
+		if !s.canDisable {
+			setting.Level = Low
+			setting.Config.IncludeThoughts = true
+			setting.Note = model + " can't turn thinking off"
+			budget = s.minBudget
+		}
+	case Low:
+		budget = clamp(lowBudget, s.minBudget, s.maxBudget)
+	case Medium:
+		budget = clamp(mediumBudget, s.minBudget, s.maxBudget)
+	case High:
+		budget = s.maxBudget
+	case Dynamic:
+		budget = -1
+	}
+	setting.Config.ThinkingBudget = &budget
+	return setting
+}
+
+// resolveLevel handles models that take a thinking level instead of a
+// budget. None of them can stop thinking entirely, so off means the minimal
+// level where there is one, with its thoughts left out.
+func resolveLevel(model string, level Level, levels []genai.ThinkingLevel) Setting {
+	setting := Setting{Level: level, Config: &genai.ThinkingConfig{IncludeThoughts: level != Off}}
+	want := map[Level]genai.ThinkingLevel{
+		Off:    genai.ThinkingLevelMinimal,
+		Low:    genai.ThinkingLevelLow,
+		Medium: genai.ThinkingLevelMedium,
+		High:   genai.ThinkingLevelHigh,
+	}[level]
+	switch {
+	case level == Dynamic:
+		// Leave the level to the model's default
+	case slices.Contains(levels, want):
+		setting.Config.ThinkingLevel = want
+	case level == Off:
+		setting.Level = Low
+		setting.Config.IncludeThoughts = true
+		setting.Config.ThinkingLevel = levels[0]
+		setting.Note = model + " can't turn thinking off"
+	default:
+		// The model lacks this level; use the next one up
+		setting.Level = High
+		setting.Config.ThinkingLevel = genai.ThinkingLevelHigh
+		setting.Note = fmt.Sprintf("%s has no %s level", model, level)
+	}
+	return setting
+}
+
+func clamp(budget, lo, hi int32) int32 {
+	return max(lo, min(budget, hi))
+}
+
+// String describes the setting for the status bar, e.g. "medium (8192)"
+func (s Setting) String() string {
+	switch {
+	case s.Config != nil && s.Config.ThinkingLevel == genai.ThinkingLevelMinimal:
+		return "minimal"
+	case s.Config == nil || s.Level == Off:
+		return string(s.Level)
+	case s.Config.ThinkingBudget != nil && *s.Config.ThinkingBudget > 0:
+		return fmt.Sprintf("%s (%d)", s.Level, *s.Config.ThinkingBudget)
+	default:
+		return string(s.Level)
+	}
+}
@@ -0,0 +1,70 @@
+package thinking
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestResolveMapsLevelsToEachModel(t *testing.T) {
+	tests := []struct {
+		model  string
+		level  Level
+		want   Level
+		budget int32 // Checked when the model takes a budget
+		think  genai.ThinkingLevel
+		note   bool
+	}{
+		{model: "gemini-2.5-flash", level: Off, want: Off, budget: 0},
+		{model: "gemini-2.5-flash", level: Medium, want: Medium, budget: 8192},
+		{model: "gemini-2.5-flash", level: High, want: High, budget: 24576},
+		{model: "gemini-2.5-flash", level: Dynamic, want: Dynamic, budget: -1},
+		{model: "gemini-2.5-flash-lite", level: Low, want: Low, budget: 1024},
+		{model: "gemini-2.5-pro", level: Off, want: Low, budget: 128, note: true},
+		{model: "gemini-2.5-pro", level: High, want: High, budget: 32768},
+		{model: "gemini-3-pro-preview", level: Low, want: Low, think: genai.ThinkingLevelLow},
+		{model: "gemini-3-pro-preview", level: Medium, want: High, think: genai.ThinkingLevelHigh, note: true},
+		{model: "gemini-3-pro-preview", level: Off, want: Low, think: genai.ThinkingLevelLow, note: true},
+		{model: "gemini-3-flash-preview", level: Off, want: Off, think: genai.ThinkingLevelMinimal},
+		{model: "gemini-3-flash-preview", level: Medium, want: Medium, think: genai.ThinkingLevelMedium},
+	}
+	for _, tt := range tests {
+		got := Resolve(tt.model, tt.level)
+		if got.Level != tt.want || (got.Note != "") != tt.note {
+			t.Errorf("%s %s: got level %s, note %q", tt.model, tt.level, got.Level, got.Note)
+			continue
+		}
+		if tt.think != "" {
+			if got.Config.ThinkingLevel != tt.think || got.Config.ThinkingBudget != nil {
+				t.Errorf("%s %s: got thinking level %q", tt.model, tt.level, got.Config.ThinkingLevel)
+			}
+			continue
+		}
+		if got.Config.ThinkingBudget == nil || *got.Config.ThinkingBudget != tt.budget {
+			t.Errorf("%s %s: got budget %v, want %d", tt.model, tt.level, got.Config.ThinkingBudget, tt.budget)
+		}
+	}
+}
+
+func TestOffOnMinimalLevelHidesThoughts(t *testing.T) {
+	got := Resolve("gemini-3-flash-preview", Off)
+	if got.Config.IncludeThoughts {
+		t.Error("thoughts requested with thinking off")
+	}
+	if got.String() != "minimal" {
+		t.Errorf("status = %q, want minimal", got.String())
+	}
+	if !Resolve("gemini-3-pro-preview", Off).Config.IncludeThoughts {
+		t.Error("thoughts left out when falling back to low")
+	}
+}
+
+func TestModelsWithoutThinking(t *testing.T) {
+	got := Resolve("gemini-2.0-flash", High)
+	if got.Level != Off || got.Config != nil || got.Note == "" {
+		t.Fatalf("got %+v", got)
+	}
+	if Supported("gemini-2.0-flash") || !Supported("gemini-2.5-pro") {
+		t.Fatal("wrong support")
+	}
+}
@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Tokens counts the tokens used by one or more requests
+type Tokens struct {
+	Input    int // Prompt tokens, including cached ones
+	Cached   int // Prompt tokens served from the context cache
+	Thinking int
+	Output   int
+}
+
+// FromMetadata reads the token counts reported with a response
+func FromMetadata(md *genai.GenerateContentResponseUsageMetadata) Tokens {
+	if md == nil {
+		return Tokens{}
+	}
+	return Tokens{
+		Input:    int(md.PromptTokenCount + md.ToolUsePromptTokenCount),
+		Cached:   int(md.CachedContentTokenCount),
+		Thinking: int(md.ThoughtsTokenCount),
+		Output:   int(md.CandidatesTokenCount),
+	}
+}
+
+// Add accumulates the counts in o
+func (t *Tokens) Add(o Tokens) {
+	t.Input += o.Input
+	t.Cached += o.Cached
+	t.Thinking += o.Thinking
+	t.Output += o.Output
+}
+
+// Total returns every token counted
+func (t Tokens) Total() int {
+	return t.Input + t.Thinking + t.Output
+}
+
+// Price is what a model costs, in US dollars per million tokens. Thinking
+// tokens are billed as output.
+type Price struct {
+	Input       float64 `toml:"input"`
+	CachedInput float64 `toml:"cached_input"`
+	Output      float64 `toml:"output"`
+}
+
+// Cost returns the price of the given tokens
+func (p Price) Cost(t Tokens) float64 {
+	uncached := t.Input - t.Cached
+	return (float64(uncached)*p.Input + float64(t.Cached)*p.CachedInput + float64(t.Thinking+t.Output)*p.Output) / 1e6
+}
+
+// Tracker keeps token totals for the current turn and for the session, and
+// their cost for the models that have a price
+type Tracker struct {
+	prices  map[string]Price
+	turn    Tokens
+	session Tokens
+	byModel map[string]Tokens
+
+	turnCost    float64
+	sessionCost float64
+	priced      bool // At least one request used a model with a price
+}
+
+// NewTracker creates a tracker with prices keyed by model name
+func NewTracker(prices map[string]Price) *Tracker {
+	return &Tracker{prices: prices, byModel: make(map[string]Tokens)}
+}
+
+// BeginTurn resets the turn totals
+func (t *Tracker) BeginTurn() {
+	t.turn = Tokens{}
+	t.turnCost = 0
+}
+
+// Record adds the usage of a request made to model
+func (t *Tracker) Record(model string, tokens Tokens) {
+	t.turn.Add(tokens)
+	t.session.Add(tokens)
+	m := t.byModel[model]
+	m.Add(tokens)
+	t.byModel[model] = m
+
+	if price, ok := t.prices[model]; ok {
+		cost := price.Cost(tokens)
+		t.turnCost += cost
+		t.sessionCost += cost
+		t.priced = true
+	}
+}
+
+// Turn returns the totals of the current turn
+func (t *Tracker) Turn() Tokens { return t.turn }
+
+// Session returns the totals since the program started
+func (t *Tracker) Session() Tokens { return t.session }
+
+// Status is a one-line summary for the status bar
+func (t *Tracker) Status() string {
+	s := fmt.Sprintf("Turn %s | Session %s", t.turn, t.session)
+	if t.priced {
+		s += fmt.Sprintf(" | %s", formatCost(t.sessionCost))
+	}
+	return s
+}
+
+// Summary describes the session's usage per model, for printing on exit
+func (t *Tracker) Summary() string {
+	if t.session.Total() == 0 {
+		return ""
+	}
+
+	models := make([]string, 0, len(t.byModel))
+	for model := range t.byModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	var sb strings.Builder
+	sb.WriteString("Token usage:\n")
+	for _, model := range models {
+		tokens := t.byModel[model]
+		fmt.Fprintf(&sb, "  %-24s %d input (%d cached), %d thinking, %d output", model, tokens.Input, tokens.Cached, tokens.Thinking, tokens.Output)
+		if price, ok := t.prices[model]; ok {
+			fmt.Fprintf(&sb, "  %s", formatCost(price.Cost(tokens)))
+		}
+		sb.WriteString("\n")
+	}
+	if t.priced {
+		fmt.Fprintf(&sb, "  Total cost: %s\n", formatCost(t.sessionCost))
+	}
+	return sb.String()
+}
+
+// String formats the counts compactly, e.g. "12.3k in / 1.2k out", noting
+// the share of input served from the cache
+func (t Tokens) String() string {
+	in := formatCount(t.Input) + " in"
+	if t.Cached > 0 && t.Input > 0 {
+		in += fmt.Sprintf(" (%d%% cached)", t.Cached*100/t.Input)
+	}
+	return fmt.Sprintf("%s / %s out", in, formatCount(t.Thinking+t.Output))
+}
+
+func formatCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+func formatCost(usd float64) string {
+	if usd < 0.01 {
+		return fmt.Sprintf("$%.4f", usd)
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestTrackerTotalsTurnsAndSession(t *testing.T) {
+	tracker := NewTracker(map[string]Price{
+		"pro": {Input: 1.25, CachedInput: 0.25, Output: 10},
+	})
+
+	tracker.BeginTurn()
+	tracker.Record("pro", FromMetadata(&genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:        1_000_000,
+		CachedContentTokenCount: 400_000,
+		ThoughtsTokenCount:      50_000,
+		CandidatesTokenCount:    50_000,
+	}))
+	tracker.Record("flash", Tokens{Input: 2000, Output: 100})
+
+	if got := tracker.Turn(); got != (Tokens{Input: 1_002_000, Cached: 400_000, Thinking: 50_000, Output: 50_100}) {
+		t.Fatalf("turn totals = %+v", got)
+	}
+	// 600k uncached * 1.25 + 400k cached * 0.25 + 100k output * 10, per million
+	if want := 0.75 + 0.1 + 1.0; math.Abs(tracker.sessionCost-want) > 1e-9 {
+		t.Fatalf("session cost = %f, want %f", tracker.sessionCost, want)
+	}
+
+	tracker.BeginTurn()
+	tracker.Record("flash", Tokens{Input: 10, Output: 5})
+	if got := tracker.Turn(); got != (Tokens{Input: 10, Output: 5}) {
+		t.Fatalf("second turn totals = %+v", got)
+	}
+	if got := tracker.Session().Total(); got != 1_002_010+100_105 {
+		t.Fatalf("session total = %d", got)
+	}
+
+	if status := tracker.Status(); status != "Turn 10 in / 5 out | Session 1.0M in (39% cached) / 100.1k out | $1.85" {
+		t.Fatalf("status = %q", status)
+	}
+	summary := tracker.Summary()
+	if !strings.Contains(summary, "flash") || !strings.Contains(summary, "Total cost: $1.85") {
+		t.Fatalf("summary missing details:\n%s", summary)
+	}
+}
+
+func TestUnpricedModelsShowNoCost(t *testing.T) {
+	tracker := NewTracker(nil)
+	tracker.Record("local", Tokens{Input: 1500, Output: 20})
+	if status := tracker.Status(); strings.Contains(status, "$") {
+		t.Fatalf("status shows a cost without prices: %q", status)
+	}
+}
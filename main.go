@@ -1,11 +1,20 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/auth/credentials"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,7 +22,15 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"google.golang.org/genai"
 
-	"github.com/haljac/gemini-tui/internal/tools"
+	"github.com/haljac/gemini-tui/internal/attach"
+	"github.com/haljac/gemini-tui/internal/compact"
+	"github.com/haljac/gemini-tui/internal/config"
+	"github.com/haljac/gemini-tui/internal/instructions"
+	"github.com/haljac/gemini-tui/internal/params"
+	"github.com/haljac/gemini-tui/internal/provider"
+	"github.com/haljac/gemini-tui/internal/thinking"
+	"github.com/haljac/gemini-tui/tools"
+	"github.com/haljac/gemini-tui/internal/usage"
 )
 
 // version is set via ldflags at build time
@@ -72,17 +89,35 @@ var (
 				Foreground(lipgloss.Color("82")).
 				Background(lipgloss.Color("236")).
 				Padding(0, 1)
+
+	statusWarningStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("214")).
+				Background(lipgloss.Color("236")).
+				Padding(0, 1)
+
+	chipStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("252")).
+			Background(lipgloss.Color("238")).
+			Padding(0, 1)
+
+	approvalStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Bold(true)
 )
 
 type message struct {
-	role      string
-	content   string
-	thinking  string   // Model's thinking process (if thinking mode enabled)
-	toolsUsed []string // Track which tools were used for this response
+	role         string
+	content      string
+	turn         int           // Conversation turn number for user messages
+	thinking     string        // Model's thinking process (if thinking is on)
+	thinkingTime time.Duration // How long the model spent thinking
+	toolsUsed    []string      // Track which tools were used for this response
+	interrupted  bool          // The user stopped the response before it finished
+	attachments  []string      // Labels of the files sent with a user message
 }
 
 type model struct {
-	client       *genai.Client
+	provider     provider.Provider
 	toolExecutor *tools.Executor
 	viewport     viewport.Model
 	textarea     textarea.Model
@@ -98,21 +133,126 @@ type model struct {
 	// Streaming state
 	streaming       bool
 	streamBuffer    string
-	streamThinking  string
+	streamThinking  string        // Thoughts received so far this turn
+	thinkingSince   time.Time     // When the current stretch of thinking began; zero when not thinking
+	thinkingTime    time.Duration // Earlier stretches of thinking this turn
+	thinkingTicking bool          // A thinkingTickMsg is scheduled
 	streamToolsUsed []string
 	streamChan      chan streamEvent
-	// Thinking mode
-	thinkingEnabled bool
-	currentModel    string
-	showThinking    bool // Toggle to show/hide thinking in UI
+	// Thinking
+	thinkingLevel thinking.Level // Level asked for; models that can't provide it use the closest one
+	currentModel  string
+	showThinking  bool // Toggle to show/hide thinking in UI
+	// Tool approval
+	toolBatch        *toolBatch
+	awaitingApproval bool
+	// Interrupting the turn cancels this context, stopping the request to
+	// Gemini and any running tool
+	turnCtx          context.Context
+	cancelTurn       context.CancelFunc
+	turnConversation []*genai.Content // History sent with the turn's latest request
+	quitPending      bool             // Ctrl+C was pressed once; a second press quits
+	// Messages typed while Gemini is working
+	pending []pendingMessage
+	// Retries of failed requests
+	retryConfig config.RetryConfig
+	retryUntil  time.Time // When the pending retry starts
+	retryLabel  string    // Status bar note for the pending retry
+	// Token usage and cost
+	usage *usage.Tracker
+	// Keeping the history within the context window
+	compaction config.CompactionConfig
+	compacting bool // /compact is summarizing the history
+	// Context caching
+	cache  config.CacheConfig
+	pinned []string // Files sent ahead of the conversation, relative to the project
+	// Generation parameters: defaults per model from the config, and values
+	// set with /set, which take precedence
+	modelParams   map[string]params.Params
+	sessionParams params.Params
+	// Files attached with /attach, sent with the next message
+	attachments []*attach.Attachment
+	// GEMINI.md and AGENTS.md files merged into the system prompt
+	instructions []instructions.File
+	// Checkpoints
+	turn int // Number of the current user turn, used by /undo and /rewind
+}
+
+// pendingMessage is a message submitted during a turn. A steering note is
+// handed to the model with the next batch of tool results; anything still
+// pending when the turn ends is sent as the following message.
+type pendingMessage struct {
+	content string
+	steer   bool
+}
+
+// toolBatch tracks the function calls from one model response while they are
+// executed, so execution can pause for user approval and resume afterwards
+type toolBatch struct {
+	calls        []*genai.FunctionCall
+	conversation []*genai.Content
+	results      []map[string]any // Indexed like calls; nil until the call finishes
+	status       []callStatus     // Indexed like calls
+	next         int              // Index of the next call to start
+	running      int              // Calls started but not yet finished
+}
+
+// callStatus is the progress of a single call in a toolBatch
+type callStatus int
+
+const (
+	callPending callStatus = iota
+	callRunning
+	callDone
+	callFailed
+)
+
+func newToolBatch(calls []*genai.FunctionCall, conversation []*genai.Content) *toolBatch {
+	return &toolBatch{
+		calls:        calls,
+		conversation: conversation,
+		results:      make([]map[string]any, len(calls)),
+		status:       make([]callStatus, len(calls)),
+	}
+}
+
+// record stores the result of call i
+func (b *toolBatch) record(i int, result map[string]any) {
+	b.results[i] = result
+	b.status[i] = callDone
+	if _, failed := result["error"]; failed {
+		b.status[i] = callFailed
+	}
+}
+
+// responses returns the function responses in the order the calls were made
+func (b *toolBatch) responses() []*genai.Part {
+	parts := make([]*genai.Part, len(b.calls))
+	for i, call := range b.calls {
+		parts[i] = genai.NewPartFromFunctionResponse(call.Name, b.results[i])
+		parts[i].FunctionResponse.ID = call.ID
+	}
+	return parts
+}
+
+// toolNames returns the name of every call in the batch
+func (b *toolBatch) toolNames() []string {
+	names := make([]string, len(b.calls))
+	for i, call := range b.calls {
+		names[i] = call.Name
+	}
+	return names
 }
 
 // Streaming event types
 type streamEvent struct {
 	chunk         string
-	thinking      string
+	thought       string // Thinking text, streamed as it arrives
 	done          bool
 	err           error
+	retry         *retryStatus
+	compaction    *compactionMsg
+	usage         usage.Tokens // Tokens used by the request, sent with done
 	functionCalls []*genai.FunctionCall
 	conversation  []*genai.Content
 }
@@ -128,27 +268,66 @@ type functionCallMsg struct {
 	conversation []*genai.Content
 }
 
+// toolResultMsg carries the result of a tool call that ran in the background
+type toolResultMsg struct {
+	batch  *toolBatch
+	index  int
+	result map[string]any
+}
+
 // Streaming message types
 type streamChunkMsg struct {
 	chunk string
 }
 
+type streamThoughtMsg struct {
+	chunk string
+}
+
+// thinkingTickMsg refreshes the thinking timer
+type thinkingTickMsg struct{}
+
 type streamDoneMsg struct {
-	fullContent string
-	thinking    string
-	toolsUsed   []string
+	fullContent  string
+	toolsUsed    []string
+	conversation []*genai.Content // History including this turn's tool calls and results
+	usage        usage.Tokens
 }
 
 type streamErrorMsg struct {
 	err error
 }
 
+// retryStatus describes a failed request that will be retried
+type retryStatus struct {
+	attempt int           // Number of the attempt that failed
+	delay   time.Duration // Wait before the next attempt
+	err     error
+	model   string // Model for the next attempt, which changes on fallback
+}
+
+type streamRetryMsg struct {
+	retry *retryStatus
+}
+
+// compactionMsg reports a compacted history, from /compact or automatically
+// before a request
+type compactionMsg struct {
+	result *compact.Result
+	err    error
+	manual bool
+}
+
+// retryTickMsg refreshes the retry countdown in the status bar
+type retryTickMsg struct{}
+
 type streamFunctionCallMsg struct {
 	calls        []*genai.FunctionCall
 	conversation []*genai.Content
+	usage        usage.Tokens
 }
 
-func initialModel(client *genai.Client, executor *tools.Executor) model {
+func initialModel(prov provider.Provider, executor *tools.Executor, cfg *config.Config) model {
 	ta := textarea.New()
 	ta.Placeholder = "Type your message..."
 	ta.Focus()
@@ -164,17 +343,75 @@ func initialModel(client *genai.Client, executor *tools.Executor) model {
 		glamour.WithWordWrap(80),
 	)
 
-	return model{
-		client:          client,
-		toolExecutor:    executor,
-		textarea:        ta,
-		messages:        []message{},
-		conversation:    []*genai.Content{},
-		mdRenderer:      mdRenderer,
-		currentModel:    ModelFlash20,
-		thinkingEnabled: false,
-		showThinking:    true,
+	// Start with the configured model if this provider offers it
+	currentModel := prov.Models()[0]
+	if slices.Contains(prov.Models(), cfg.Model) {
+		currentModel = cfg.Model
+	}
+
+	m := model{
+		provider:      prov,
+		toolExecutor:  executor,
+		textarea:      ta,
+		messages:      []message{},
+		conversation:  []*genai.Content{},
+		mdRenderer:    mdRenderer,
+		currentModel:  currentModel,
+		thinkingLevel: thinkingLevel(cfg.Thinking),
+		showThinking:  cfg.Thinking.Show,
+		retryConfig:   cfg.Retry,
+		usage:         usage.NewTracker(cfg.Prices),
+		compaction:    cfg.Compaction,
+		cache:         cfg.Cache,
+		pinned:        cfg.Cache.Pinned,
+		modelParams:   cfg.Generation,
 	}
+	m.loadInstructions()
+	return m
+}
+
+// thinkingLevel returns the configured thinking level. main has already
+// checked that it is valid.
+func thinkingLevel(cfg config.ThinkingConfig) thinking.Level {
+	if cfg.Level != "" {
+		level, _ := thinking.ParseLevel(cfg.Level)
+		return level
+	}
+	if cfg.Enabled {
+		return thinking.Dynamic
+	}
+	return thinking.Off
+}
+
+// setThinking changes the thinking level, rejecting any level but off for
+// models that can't think. OpenAI-compatible servers take no thinking
+// settings, so the level can't be changed with them.
+func (m *model) setThinking(level thinking.Level) {
+	if m.provider.Name() == "openai" {
+		m.err = fmt.Errorf("thinking levels can't be set for OpenAI-compatible servers")
+		return
+	}
+	if level != thinking.Off && !thinking.Supported(m.currentModel) {
+		m.err = fmt.Errorf("%s doesn't support thinking", m.currentModel)
+		return
+	}
+	m.err = nil
+	m.thinkingLevel = level
+}
+
+// generationParams returns the parameters in effect for modelName
+func (m *model) generationParams(modelName string) params.Params {
+	return m.modelParams[modelName].Merge(m.sessionParams)
+}
+
+// loadInstructions reads the instruction files for the system prompt
+func (m *model) loadInstructions() {
+	files, err := instructions.Load(config.Dir(), m.toolExecutor)
+	if err != nil {
+		m.err = fmt.Errorf("loading instructions: %w", err)
+		return
+	}
+	m.instructions = files
 }
 
 func (m model) Init() tea.Cmd {
@@ -182,24 +419,327 @@ func (m model) Init() tea.Cmd {
 }
 
 func (m *model) nextModel() string {
-	for i, model := range availableModels {
+	models := m.provider.Models()
+	for i, model := range models {
 		if model == m.currentModel {
-			return availableModels[(i+1)%len(availableModels)]
+			return models[(i+1)%len(models)]
+		}
+	}
+	return models[0]
+}
+
+// startTurn begins a new conversation turn with the user's message
+func (m *model) startTurn(userInput string) tea.Cmd {
+	attachments, err := m.takeAttachments(userInput)
+	if err != nil {
+		m.err = err
+		m.textarea.SetValue(userInput)
+		return nil
+	}
+	var labels []string
+	for _, a := range attachments {
+		labels = append(labels, a.Label())
+	}
+
+	m.turn++
+	m.toolExecutor.BeginTurn(m.turn)
+	m.usage.BeginTurn()
+	if m.cancelTurn != nil {
+		m.cancelTurn()
+	}
+	m.turnCtx, m.cancelTurn = context.WithCancel(context.Background())
+	m.messages = append(m.messages, message{role: "user", content: userInput, turn: m.turn, attachments: labels})
+	m.err = nil
+	m.waiting = true
+	m.streaming = true
+	m.streamBuffer = ""
+	m.streamThinking = ""
+	m.thinkingSince = time.Time{}
+	m.thinkingTime = 0
+	m.activeTools = nil
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m.sendMessage(userInput, attachments)
+}
+
+// takeAttachments returns the files attached with /attach and any named by
+// @path in text, and clears the /attach list
+func (m *model) takeAttachments(text string) ([]*attach.Attachment, error) {
+	attachments := slices.Clone(m.attachments)
+	for _, path := range attach.References(m.toolExecutor, text) {
+		if slices.ContainsFunc(attachments, func(a *attach.Attachment) bool { return a.Path == path }) {
+			continue
+		}
+		a, err := attach.Load(m.toolExecutor, path)
+		if err == nil {
+			err = m.checkAttachment(a)
+		}
+		if err != nil {
+			return nil, err
 		}
+		attachments = append(attachments, a)
+	}
+	if err := attach.CheckTotal(attachments, m.conversation); err != nil {
+		return nil, err
+	}
+	m.attachments = nil
+	return attachments, nil
+}
+
+// checkAttachment returns an error if the provider can't take a
+func (m *model) checkAttachment(a *attach.Attachment) error {
+	if !a.Text() && m.provider.Name() == "openai" {
+		return fmt.Errorf("can't attach %s: only text files can be sent to OpenAI-compatible servers", a.Path)
+	}
+	return nil
+}
+
+// addPending holds a message typed during a turn until it can be delivered
+func (m *model) addPending(content string, steer bool) {
+	m.err = nil
+	if strings.HasPrefix(content, "/") {
+		m.err = fmt.Errorf("commands can't be run while Gemini is working")
+		return
+	}
+	m.pending = append(m.pending, pendingMessage{content: content, steer: steer})
+	m.textarea.Reset()
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// takeSteering removes the pending steering notes and returns them as parts
+// to send along with the next tool results
+func (m *model) takeSteering() []*genai.Part {
+	var parts []*genai.Part
+	var rest []pendingMessage
+	for _, p := range m.pending {
+		if !p.steer {
+			rest = append(rest, p)
+			continue
+		}
+		m.messages = append(m.messages, message{role: "user", content: p.content, turn: m.turn})
+		parts = append(parts, &genai.Part{Text: "[Message from the user while you were working] " + p.content})
+	}
+	m.pending = rest
+	return parts
+}
+
+// restorePending moves undelivered messages back into the input box when a
+// turn ends early, so they can be edited or sent again
+func (m *model) restorePending() {
+	if len(m.pending) == 0 {
+		return
+	}
+	var texts []string
+	for _, p := range m.pending {
+		texts = append(texts, p.content)
+	}
+	if current := strings.TrimSpace(m.textarea.Value()); current != "" {
+		texts = append(texts, current)
 	}
-	return availableModels[0]
+	m.pending = nil
+	m.textarea.SetValue(strings.Join(texts, "\n"))
 }
 
-func (m *model) sendMessage(userMsg string) tea.Cmd {
-	// Build conversation with current user message
+func (m *model) sendMessage(userMsg string, attachments []*attach.Attachment) tea.Cmd {
+	// Build conversation with current user message and its attachments
+	parts := []*genai.Part{{Text: userMsg}}
+	for _, a := range attachments {
+		parts = append(parts, a.Parts()...)
+	}
 	conversation := append(m.conversation, &genai.Content{
 		Role:  "user",
-		Parts: []*genai.Part{{Text: userMsg}},
+		Parts: parts,
 	})
 
 	return m.startStreaming(conversation, nil)
 }
 
+// runToolBatch starts the next pending function calls once the previous ones
+// have finished. A run of consecutive read-only calls is started at once and
+// executes concurrently; any other call runs on its own, pausing first if it
+// needs the user's approval. Once every call has a result the conversation
+// continues with the function responses.
+func (m *model) runToolBatch() tea.Cmd {
+	batch := m.toolBatch
+	if batch.running > 0 {
+		return nil
+	}
+	if batch.next < len(batch.calls) {
+		registry := m.toolExecutor.Registry()
+		call := batch.calls[batch.next]
+		if registry.RequiresApproval(call.Name) {
+			m.awaitingApproval = true
+			m.viewport.SetContent(m.renderMessages())
+			m.viewport.GotoBottom()
+			return nil
+		}
+		if !registry.IsReadOnly(call.Name) {
+			cmd := m.executeTool(batch.next)
+			batch.next++
+			return cmd
+		}
+
+		var cmds []tea.Cmd
+		for batch.next < len(batch.calls) {
+			name := batch.calls[batch.next].Name
+			if !registry.IsReadOnly(name) || registry.RequiresApproval(name) {
+				break
+			}
+			cmds = append(cmds, m.executeTool(batch.next))
+			batch.next++
+		}
+		return tea.Batch(cmds...)
+	}
+	m.toolBatch = nil
+
+	// Update active tools for UI feedback
+	toolNames := batch.toolNames()
+	m.activeTools = toolNames
+	m.streamToolsUsed = toolNames
+	m.streaming = true
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+
+	// Add function responses to conversation, along with any steering notes
+	conversation := append(batch.conversation, &genai.Content{
+		Role:  "user",
+		Parts: append(batch.responses(), m.takeSteering()...),
+	})
+
+	// Continue the conversation with function results
+	return m.continueWithFunctionResults(conversation, toolNames)
+}
+
+// resolveApproval runs or rejects the call awaiting approval and resumes the batch
+func (m *model) resolveApproval(approved bool) tea.Cmd {
+	m.awaitingApproval = false
+	batch := m.toolBatch
+	i := batch.next
+	batch.next++
+	if approved {
+		return m.executeTool(i)
+	}
+	batch.record(i, map[string]any{"error": "the user declined this tool call"})
+	return m.runToolBatch()
+}
+
+// executeTool runs call i of the batch in the background so the UI stays
+// responsive and the turn can be interrupted while the tool is working
+func (m *model) executeTool(i int) tea.Cmd {
+	batch := m.toolBatch
+	call := batch.calls[i]
+	batch.status[i] = callRunning
+	batch.running++
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+
+	executor, ctx := m.toolExecutor, m.turnCtx
+	return func() tea.Msg {
+		result, err := executor.Execute(ctx, call.Name, call.Args)
+		if err != nil {
+			result = map[string]any{"error": err.Error()}
+		}
+		return toolResultMsg{batch: batch, index: i, result: result}
+	}
+}
+
+// interruptTurn cancels the request to Gemini and any running tools. The
+// partial answer stays in the transcript, and the history keeps everything
+// up to the interruption so the next message can pick up from there.
+func (m *model) interruptTurn() {
+	if m.cancelTurn != nil {
+		m.cancelTurn()
+	}
+
+	if m.compacting {
+		m.compacting = false
+		m.waiting = false
+		m.addSystemMessage("Compaction cancelled")
+		m.restorePending()
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return
+	}
+
+	if batch := m.toolBatch; batch != nil {
+		// Calls that never finished still need a response
+		for i, result := range batch.results {
+			if result == nil {
+				batch.record(i, map[string]any{"error": "interrupted by the user"})
+			}
+		}
+		m.conversation = append(batch.conversation, &genai.Content{
+			Role:  "user",
+			Parts: batch.responses(),
+		})
+		m.addSystemMessage(fmt.Sprintf("Interrupted while running tools: %s", strings.Join(batch.toolNames(), ", ")))
+	} else {
+		m.stopThinking()
+		m.conversation = append(m.turnConversation, &genai.Content{
+			Role:  "model",
+			Parts: []*genai.Part{{Text: m.streamBuffer + "\n\n[interrupted by the user]"}},
+		})
+		m.messages = append(m.messages, message{
+			role:         "assistant",
+			content:      m.streamBuffer,
+			thinking:     m.streamThinking,
+			thinkingTime: m.thinkingTime,
+			toolsUsed:    m.streamToolsUsed,
+			interrupted:  true,
+		})
+	}
+
+	m.toolBatch = nil
+	m.awaitingApproval = false
+	m.waiting = false
+	m.streaming = false
+	m.streamBuffer = ""
+	m.streamThinking = ""
+	m.thinkingSince = time.Time{}
+	m.activeTools = nil
+	m.restorePending()
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// compactLimit returns how many tokens of history may be sent to modelName
+// before it is compacted, or 0 if automatic compaction is off
+func (m *model) compactLimit(modelName string) int {
+	if m.compaction.Threshold <= 0 {
+		return 0
+	}
+	window := compact.Window(modelName)
+	if m.compaction.ContextWindow > 0 {
+		window = m.compaction.ContextWindow
+	}
+	return int(float64(window) * m.compaction.Threshold)
+}
+
+// startCompaction summarizes the older turns of the history in the background
+func (m *model) startCompaction() tea.Cmd {
+	if _, turns := compact.Split(m.conversation, m.compaction.KeepTurns); turns == 0 {
+		m.addSystemMessage("Nothing to compact yet; the latest turns are always kept in full")
+		return nil
+	}
+	if m.cancelTurn != nil {
+		m.cancelTurn()
+	}
+	m.turnCtx, m.cancelTurn = context.WithCancel(context.Background())
+	m.compacting = true
+	m.waiting = true
+
+	ctx, prov, modelName, conversation := m.turnCtx, m.provider, m.currentModel, m.conversation
+	opts := compact.Options{KeepTurns: m.compaction.KeepTurns}
+	return func() tea.Msg {
+		result, err := compact.Compact(ctx, prov, modelName, conversation, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return compactionMsg{result: result, err: err, manual: true}
+	}
+}
+
 func (m *model) continueWithFunctionResults(conversation []*genai.Content, toolsUsed []string) tea.Cmd {
 	return m.startStreaming(conversation, toolsUsed)
 }
@@ -209,75 +749,41 @@ func (m *model) startStreaming(conversation []*genai.Content, toolsUsed []string
 	ch := make(chan streamEvent, 10)
 	m.streamChan = ch
 	m.streamToolsUsed = toolsUsed
+	m.turnConversation = conversation
 
 	// Start streaming in background
-	go m.streamInBackground(conversation, toolsUsed, ch)
+	go m.streamInBackground(m.turnCtx, conversation, toolsUsed, ch)
 
 	// Return command to wait for first event
 	return m.waitForStreamEvent()
 }
 
-func (m *model) streamInBackground(conversation []*genai.Content, toolsUsed []string, ch chan streamEvent) {
+func (m *model) streamInBackground(ctx context.Context, conversation []*genai.Content, toolsUsed []string, ch chan streamEvent) {
 	defer close(ch)
 
-	ctx := context.Background()
-
-	// Configure with tools and system instruction
-	config := &genai.GenerateContentConfig{
-		SystemInstruction: &genai.Content{
-			Parts: []*genai.Part{{
-				Text: `You are an expert coding agent. You help users write, modify, debug, and understand code. You can read, create, and edit files in the user's project.
-
-## Core Principles
-
-1. **Understand before acting**: Read relevant files before making changes. Explore the codebase to understand patterns and conventions.
-2. **Make surgical edits**: Use edit_file for small changes to existing files. Use write_file for new files or complete rewrites.
-3. **Explain your changes**: Briefly describe what you're doing and why.
-4. **Follow existing patterns**: Match the code style, naming conventions, and architecture of the project.
-
-## Tools Available
-
-Reading:
-- read_file: Read file contents
-- list_directory: List directory contents
-- glob_search: Find files by pattern (e.g., '**/*.go')
-
-Writing:
-- write_file: Create new files or overwrite existing files
-- edit_file: Make surgical edits by replacing specific strings (old_string must be unique)
-- create_directory: Create directories
-
-## Best Practices
-
-- Always read a file before editing it
-- When editing, include enough context in old_string to make it unique
-- Create parent directories before writing files to new paths
-- For multi-file changes, handle them one at a time
-- If an edit fails because old_string isn't unique, include more surrounding context`,
-			}},
-		},
-		Tools: []*genai.Tool{{
-			FunctionDeclarations: tools.AllTools(),
-		}},
-	}
-
-	// Add thinking config if enabled
-	if m.thinkingEnabled {
-		config.ThinkingConfig = &genai.ThinkingConfig{
-			IncludeThoughts: true,
+	// Nobody reads the channel once the turn is interrupted
+	send := func(event streamEvent) bool {
+		select {
+		case ch <- event:
+			return true
+		case <-ctx.Done():
+			return false
 		}
 	}
 
+	// The system instruction, tools and pinned files start every request
+	prefix := m.requestPrefix()
+
 	var fullText strings.Builder
-	var thinkingText strings.Builder
 	var functionCalls []*genai.FunctionCall
 	var functionCallParts []*genai.Part // Preserve original parts with ThoughtSignature
+	var textSignature []byte            // ThoughtSignature sent with the text, if any
+	var tokens usage.Tokens
 
-	// Stream the response
-	for resp, err := range m.client.Models.GenerateContentStream(ctx, m.currentModel, conversation, config) {
-		if err != nil {
-			ch <- streamEvent{err: err}
-			return
+	collect := func(resp *genai.GenerateContentResponse) {
+		// Each chunk reports the usage of the request so far
+		if resp.UsageMetadata != nil {
+			tokens = usage.FromMetadata(resp.UsageMetadata)
 		}
 
 		// Check for function calls in this chunk
@@ -291,53 +797,218 @@ Writing:
 				if part.Thought {
 					// This is thinking content
 					if part.Text != "" {
-						thinkingText.WriteString(part.Text)
+						send(streamEvent{thought: part.Text})
 					}
 				} else if part.Text != "" {
 					// Regular text content
 					fullText.WriteString(part.Text)
-					ch <- streamEvent{chunk: part.Text}
+					send(streamEvent{chunk: part.Text})
 				} else if part.FunctionCall != nil {
 					// Preserve original function call parts (includes ThoughtSignature)
 					functionCallParts = append(functionCallParts, part)
 				}
+				if part.FunctionCall == nil && len(part.ThoughtSignature) > 0 {
+					textSignature = part.ThoughtSignature
+				}
 			}
 		}
 	}
 
-	// If we have function calls, send them
-	if len(functionCalls) > 0 {
-		// Build the model's response content for conversation history
-		// Use original parts to preserve ThoughtSignature
-		var parts []*genai.Part
-		if fullText.Len() > 0 {
-			parts = append(parts, &genai.Part{Text: fullText.String()})
-		}
-		// Use the preserved original parts that include ThoughtSignature
-		parts = append(parts, functionCallParts...)
-		newConversation := append(conversation, &genai.Content{
+	// Compact the history first if it's close to filling the context window
+	modelName := m.currentModel
+	if limit := m.compactLimit(modelName); limit > 0 && compact.Estimate(conversation) > limit {
+		opts := compact.Options{KeepTurns: m.compaction.KeepTurns, Limit: limit}
+		result, err := compact.Compact(ctx, m.provider, modelName, conversation, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || result.Turns > 0 {
+			if !send(streamEvent{compaction: &compactionMsg{result: result, err: err}}) {
+				return
+			}
+		}
+		if err == nil {
+			conversation = result.Contents
+		}
+	}
+
+	// Stream the response, retrying transient failures such as rate limits
+	// as long as nothing has been received yet
+	retry := m.retryConfig
+	failures := 0
+	for attempt := 1; ; attempt++ {
+		var err error
+		received := false
+		request, config := m.prepareRequest(ctx, modelName, prefix, conversation)
+		for resp, streamErr := range m.provider.GenerateContentStream(ctx, modelName, request, config) {
+			if streamErr != nil {
+				err = streamErr
+				break
+			}
+			received = true
+			collect(resp)
+		}
+		if err == nil {
+			break
+		}
+		retryAfter, retryable := provider.Retryable(err)
+		if !retryable || received || attempt >= retry.MaxAttempts || ctx.Err() != nil {
+			send(streamEvent{err: err})
+			return
+		}
+
+		failures++
+		if retry.FallbackModel != "" && modelName != retry.FallbackModel && failures >= retry.FallbackAfter {
+			modelName = retry.FallbackModel
+		}
+		delay := provider.Backoff(attempt, retryAfter)
+		if !send(streamEvent{retry: &retryStatus{attempt: attempt, delay: delay, err: err, model: modelName}}) {
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	// Build the model's response content for conversation history
+	var parts []*genai.Part
+	if fullText.Len() > 0 || len(textSignature) > 0 {
+		parts = append(parts, &genai.Part{Text: fullText.String(), ThoughtSignature: textSignature})
+	}
+	// Use the preserved original parts that include ThoughtSignature
+	parts = append(parts, functionCallParts...)
+	newConversation := conversation
+	if len(parts) > 0 {
+		newConversation = append(conversation, &genai.Content{
 			Role:  "model",
 			Parts: parts,
 		})
-		ch <- streamEvent{
+	}
+
+	// If we have function calls, send them
+	if len(functionCalls) > 0 {
+		send(streamEvent{
 			done:          true,
 			functionCalls: functionCalls,
 			conversation:  newConversation,
-		}
+			usage:         tokens,
+		})
 		return
 	}
 
-	// Done with text response
-	ch <- streamEvent{done: true, thinking: thinkingText.String()}
+	// Done with text response; the conversation holds every step of the turn
+	send(streamEvent{done: true, conversation: newConversation, usage: tokens})
+}
+
+// requestPrefix returns the part of a request that rarely changes: the
+// system instruction, the tool declarations and the pinned files
+func (m *model) requestPrefix() *provider.Prefix {
+	registry := m.toolExecutor.Registry()
+	return &provider.Prefix{
+		System: &genai.Content{
+			Parts: []*genai.Part{{Text: systemPrompt(registry, instructions.Prompt(m.instructions))}},
+		},
+		Tools: []*genai.Tool{{
+			FunctionDeclarations: registry.AllTools(),
+		}},
+		Contents: pinnedContents(m.toolExecutor, m.pinned),
+	}
+}
+
+// prepareRequest returns the contents and config for a request to
+// modelName. The prefix is kept in a context cache when the provider
+// supports one, and sent in full otherwise.
+func (m *model) prepareRequest(ctx context.Context, modelName string, prefix *provider.Prefix, conversation []*genai.Content) ([]*genai.Content, *genai.GenerateContentConfig) {
+	config := &genai.GenerateContentConfig{
+		ThinkingConfig: thinking.Resolve(modelName, m.thinkingLevel).Config,
+	}
+	m.generationParams(modelName).Apply(config)
+
+	if cacher, ok := m.provider.(provider.Cacher); ok && m.cache.Enabled {
+		if name, err := cacher.CachePrefix(ctx, modelName, prefix, m.cache.TTL); err == nil {
+			config.CachedContent = name
+			return conversation, config
+		}
+	}
+	config.SystemInstruction = prefix.System
+	config.Tools = prefix.Tools
+	return append(slices.Clip(prefix.Contents), conversation...), config
+}
+
+// pinnedContents returns the current contents of the pinned files as a
+// message to send ahead of the conversation
+func pinnedContents(executor *tools.Executor, paths []string) []*genai.Content {
+	if len(paths) == 0 {
+		return nil
+	}
+	parts := []*genai.Part{{Text: "The user pinned these project files. Their current contents follow, so there is no need to read them again."}}
+	for _, path := range paths {
+		var text string
+		resolved, err := executor.ResolvePath(path)
+		if err == nil {
+			var data []byte
+			data, err = os.ReadFile(resolved)
+			text = string(data)
+		}
+		if err != nil {
+			text = fmt.Sprintf("(could not read the file: %v)", err)
+		}
+		parts = append(parts, &genai.Part{Text: fmt.Sprintf("<file path=%q>\n%s\n</file>", path, text)})
+	}
+	return []*genai.Content{
+		{Role: "user", Parts: parts},
+		{Role: "model", Parts: []*genai.Part{{Text: "Noted. I'll work from the pinned files."}}},
+	}
+}
+
+// systemPrompt builds the system instruction, including a description of
+// every tool in the registry and the user's and project's instructions
+func systemPrompt(registry *tools.Registry, instructions string) string {
+	prompt := `You are an expert coding agent. You help users write, modify, debug, and understand code. You can read, create, and edit files in the user's project.
+
+## Core Principles
+
+1. **Understand before acting**: Read relevant files before making changes. Explore the codebase to understand patterns and conventions.
+2. **Make surgical edits**: Use edit_file for small changes to existing files. Use write_file for new files or complete rewrites.
+3. **Explain your changes**: Briefly describe what you're doing and why.
+4. **Follow existing patterns**: Match the code style, naming conventions, and architecture of the project.
+
+` + registry.PromptSection() + `
+## Best Practices
+
+- Use grep_search to locate symbols and usages instead of reading files one by one
+- Always read a file before editing it
+- When editing, include enough context in old_string to make it unique (never include read_file's line-number prefixes)
+- Create parent directories before writing files to new paths
+- For changes with many hunks or across several files, prefer a single apply_patch call
+- When splitting or renaming files, use move_path or delete_file so no stale copies are left behind
+- If an edit fails because old_string isn't unique, include more surrounding context
+- After making changes, build and run the tests with run_shell_command to verify them`
+	if instructions != "" {
+		prompt += "\n\n" + instructions
+	}
+	return prompt
+}
+
+// retryTick schedules the next update of the retry countdown
+func retryTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return retryTickMsg{} })
 }
 
 func (m *model) waitForStreamEvent() tea.Cmd {
+	ch, ctx := m.streamChan, m.turnCtx
 	return func() tea.Msg {
-		if m.streamChan == nil {
+		if ch == nil {
 			return streamErrorMsg{err: fmt.Errorf("no stream channel")}
 		}
 
-		event, ok := <-m.streamChan
+		event, ok := <-ch
+		if ctx.Err() != nil {
+			// The turn was interrupted; interruptTurn already wrapped it up
+			return nil
+		}
 		if !ok {
 			// Channel closed unexpectedly
 			return streamDoneMsg{fullContent: m.streamBuffer, toolsUsed: m.streamToolsUsed}
@@ -347,24 +1018,60 @@ func (m *model) waitForStreamEvent() tea.Cmd {
 			return streamErrorMsg{err: event.err}
 		}
 
+		if event.retry != nil {
+			return streamRetryMsg{retry: event.retry}
+		}
+
+		if event.compaction != nil {
+			return *event.compaction
+		}
+
 		if event.done {
 			if len(event.functionCalls) > 0 {
 				return streamFunctionCallMsg{
 					calls:        event.functionCalls,
 					conversation: event.conversation,
+					usage:        event.usage,
 				}
 			}
 			return streamDoneMsg{
-				fullContent: m.streamBuffer,
-				thinking:    event.thinking,
-				toolsUsed:   m.streamToolsUsed,
+				fullContent:  m.streamBuffer,
+				toolsUsed:    m.streamToolsUsed,
+				conversation: event.conversation,
+				usage:        event.usage,
 			}
 		}
 
+		if event.thought != "" {
+			return streamThoughtMsg{chunk: event.thought}
+		}
 		return streamChunkMsg{chunk: event.chunk}
 	}
 }
 
+// thinkingTick schedules the next update of the thinking timer
+func thinkingTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return thinkingTickMsg{} })
+}
+
+// stopThinking ends the current stretch of thinking, if any, adding it to
+// the turn's thinking time
+func (m *model) stopThinking() {
+	if !m.thinkingSince.IsZero() {
+		m.thinkingTime += time.Since(m.thinkingSince)
+		m.thinkingSince = time.Time{}
+	}
+}
+
+// thinkingElapsed returns how long the model has thought this turn
+func (m model) thinkingElapsed() time.Duration {
+	elapsed := m.thinkingTime
+	if !m.thinkingSince.IsZero() {
+		elapsed += time.Since(m.thinkingSince)
+	}
+	return elapsed
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		taCmd tea.Cmd
@@ -374,33 +1081,70 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
-			return m, tea.Quit
-		case tea.KeyEnter:
-			if m.waiting || m.streaming {
-				return m, nil
+		case tea.KeyCtrlC:
+			// The first press interrupts the turn; quitting takes a second one
+			if m.quitPending {
+				if m.cancelTurn != nil {
+					m.cancelTurn()
+				}
+				return m, tea.Quit
+			}
+			if m.waiting {
+				m.interruptTurn()
 			}
+			m.quitPending = true
+			return m, nil
+		case tea.KeyEsc:
+			m.quitPending = false
+			if m.waiting {
+				m.interruptTurn()
+			}
+			return m, nil
+		}
+		m.quitPending = false
+		if m.awaitingApproval {
+			switch msg.String() {
+			case "y", "Y":
+				cmd := m.resolveApproval(true)
+				return m, cmd
+			case "n", "N":
+				cmd := m.resolveApproval(false)
+				return m, cmd
+			}
+			return m, nil
+		}
+		switch msg.Type {
+		case tea.KeyEnter:
 			userInput := strings.TrimSpace(m.textarea.Value())
 			if userInput == "" {
 				return m, nil
 			}
-			m.messages = append(m.messages, message{role: "user", content: userInput})
+			if m.waiting {
+				m.addPending(userInput, true)
+				return m, nil
+			}
+			if strings.HasPrefix(userInput, "/") {
+				m.textarea.Reset()
+				cmd := m.handleCommand(userInput)
+				m.viewport.SetContent(m.renderMessages())
+				m.viewport.GotoBottom()
+				return m, cmd
+			}
 			m.textarea.Reset()
-			m.waiting = true
-			m.streaming = true
-			m.streamBuffer = ""
-			m.streamThinking = ""
-			m.activeTools = nil
-			m.viewport.SetContent(m.renderMessages())
-			m.viewport.GotoBottom()
-			cmd := m.sendMessage(userInput)
+			cmd := m.startTurn(userInput)
 			return m, cmd
 		}
 		// Handle other key combinations
 		switch msg.String() {
+		case "ctrl+q":
+			// Queue a message for after the current turn
+			if userInput := strings.TrimSpace(m.textarea.Value()); m.waiting && userInput != "" {
+				m.addPending(userInput, false)
+			}
+			return m, nil
 		case "ctrl+t":
-			// Toggle thinking mode
-			m.thinkingEnabled = !m.thinkingEnabled
+			// Cycle through thinking levels
+			m.setThinking(m.thinkingLevel.Next())
 			m.viewport.SetContent(m.renderMessages())
 			return m, nil
 		case "ctrl+g":
@@ -415,8 +1159,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+	case streamThoughtMsg:
+		// Show the model's thoughts as they arrive, timing how long it thinks
+		m.streamThinking += msg.chunk
+		var tick tea.Cmd
+		if m.thinkingSince.IsZero() {
+			m.thinkingSince = time.Now()
+			if !m.thinkingTicking {
+				m.thinkingTicking = true
+				tick = thinkingTick()
+			}
+		}
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return m, tea.Batch(m.waitForStreamEvent(), tick)
+
+	case thinkingTickMsg:
+		if m.thinkingSince.IsZero() {
+			m.thinkingTicking = false
+			return m, nil
+		}
+		m.viewport.SetContent(m.renderMessages())
+		return m, thinkingTick()
+
 	case streamChunkMsg:
 		// Append chunk to buffer and update display
+		m.stopThinking()
 		m.streamBuffer += msg.chunk
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
@@ -425,6 +1193,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case streamDoneMsg:
 		// Streaming complete - finalize the message
+		m.usage.Record(m.currentModel, msg.usage)
+		m.stopThinking()
 		m.waiting = false
 		m.streaming = false
 		m.activeTools = nil
@@ -433,65 +1203,122 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			content = m.streamBuffer
 		}
 		m.messages = append(m.messages, message{
-			role:      "assistant",
-			content:   content,
-			thinking:  msg.thinking,
-			toolsUsed: msg.toolsUsed,
+			role:         "assistant",
+			content:      content,
+			thinking:     m.streamThinking,
+			thinkingTime: m.thinkingTime,
+			toolsUsed:    msg.toolsUsed,
 		})
 		m.streamBuffer = ""
 		m.streamThinking = ""
-		// Update conversation history for next turn
-		m.conversation = append(m.conversation,
-			&genai.Content{
-				Role:  "user",
-				Parts: []*genai.Part{{Text: m.messages[len(m.messages)-2].content}},
-			},
-			&genai.Content{
-				Role:  "model",
-				Parts: []*genai.Part{{Text: content}},
-			},
-		)
+		// Keep the whole turn, tool calls and results included, for the next one
+		if msg.conversation != nil {
+			m.conversation = msg.conversation
+		}
+		if len(m.pending) > 0 {
+			next := m.pending[0]
+			m.pending = m.pending[1:]
+			cmd := m.startTurn(next.content)
+			return m, cmd
+		}
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
 		return m, nil
 
 	case streamErrorMsg:
+		m.stopThinking()
 		m.waiting = false
 		m.streaming = false
+		m.activeTools = nil
 		m.streamBuffer = ""
+		m.streamThinking = ""
 		m.err = msg.err
+		m.restorePending()
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
 		return m, nil
 
+	case streamRetryMsg:
+		retry := msg.retry
+		m.retryUntil = time.Now().Add(retry.delay)
+		m.retryLabel = fmt.Sprintf("attempt %d/%d failed", retry.attempt, m.retryConfig.MaxAttempts)
+		if retry.model != m.currentModel {
+			m.addSystemMessage(fmt.Sprintf("%s keeps failing (%v); switching to %s", m.currentModel, retry.err, retry.model))
+			m.currentModel = retry.model
+			m.viewport.SetContent(m.renderMessages())
+			m.viewport.GotoBottom()
+		}
+		return m, tea.Batch(m.waitForStreamEvent(), retryTick())
+
+	case compactionMsg:
+		if msg.manual {
+			if !m.compacting {
+				return m, nil
+			}
+			m.compacting = false
+			m.waiting = false
+			m.restorePending()
+		}
+		if msg.result != nil {
+			m.usage.Record(m.currentModel, msg.result.Usage)
+		}
+		switch {
+		case msg.err != nil && msg.manual:
+			m.err = msg.err
+		case msg.err != nil:
+			m.addSystemMessage(fmt.Sprintf("Couldn't compact the history, sending it in full: %v", msg.err))
+		case msg.result.Turns == 0:
+			m.addSystemMessage("Nothing to compact yet; the latest turns are always kept in full")
+		default:
+			r := msg.result
+			how := "shortened old tool outputs"
+			if r.Summarized {
+				how = fmt.Sprintf("summarized %d earlier turns", r.Turns)
+			}
+			m.addSystemMessage(fmt.Sprintf("Compacted the history (%s): ~%d -> ~%d tokens", how, r.Before, r.After))
+			if msg.manual {
+				m.conversation = r.Contents
+			} else {
+				m.turnConversation = r.Contents
+			}
+		}
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		if msg.manual {
+			return m, nil
+		}
+		cmd := m.waitForStreamEvent()
+		return m, cmd
+
+	case retryTickMsg:
+		if time.Now().Before(m.retryUntil) {
+			return m, retryTick()
+		}
+		return m, nil
+
 	case streamFunctionCallMsg:
 		// Execute the function calls
+		m.usage.Record(m.currentModel, msg.usage)
+		m.stopThinking()
+		if m.streamThinking != "" && !strings.HasSuffix(m.streamThinking, "\n\n") {
+			m.streamThinking += "\n\n"
+		}
 		m.streaming = false
 		m.streamBuffer = ""
-		var toolNames []string
-		var functionResponses []*genai.Part
+		m.toolBatch = newToolBatch(msg.calls, msg.conversation)
+		cmd := m.runToolBatch()
+		return m, cmd
 
-		for _, call := range msg.calls {
-			toolNames = append(toolNames, call.Name)
-			result, _ := m.toolExecutor.Execute(call.Name, call.Args)
-			functionResponses = append(functionResponses, genai.NewPartFromFunctionResponse(call.Name, result))
+	case toolResultMsg:
+		// Ignore results that arrive after the turn was interrupted
+		if msg.batch != m.toolBatch {
+			return m, nil
 		}
-
-		// Update active tools for UI feedback
-		m.activeTools = toolNames
-		m.streamToolsUsed = toolNames
-		m.streaming = true
+		msg.batch.running--
+		msg.batch.record(msg.index, msg.result)
+		cmd := m.runToolBatch()
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
-
-		// Add function responses to conversation
-		conversation := append(msg.conversation, &genai.Content{
-			Role:  "user",
-			Parts: functionResponses,
-		})
-
-		// Continue the conversation with function results
-		cmd := m.continueWithFunctionResults(conversation, toolNames)
 		return m, cmd
 
 	case tea.WindowSizeMsg:
@@ -526,6 +1353,171 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(taCmd, vpCmd)
 }
 
+// handleCommand runs a slash command typed into the input box
+func (m *model) handleCommand(input string) tea.Cmd {
+	m.err = nil
+	fields := strings.Fields(input)
+
+	switch fields[0] {
+	case "/undo":
+		turn, restored, err := m.toolExecutor.Undo()
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		m.addSystemMessage(fmt.Sprintf("Undid file changes from turn %d: %s", turn, strings.Join(restored, ", ")))
+
+	case "/rewind":
+		if len(fields) != 2 {
+			m.err = fmt.Errorf("usage: /rewind <turn>")
+			return nil
+		}
+		turn, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil || turn < 1 {
+			m.err = fmt.Errorf("invalid turn: %s", fields[1])
+			return nil
+		}
+		restored, err := m.toolExecutor.Rewind(turn)
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		m.addSystemMessage(fmt.Sprintf("Rewound file changes to before turn %d: %s", turn, strings.Join(restored, ", ")))
+
+	case "/compact":
+		return m.startCompaction()
+
+	case "/thinking":
+		if len(fields) != 2 {
+			m.err = fmt.Errorf("usage: /thinking off|low|medium|high|dynamic")
+			return nil
+		}
+		level, err := thinking.ParseLevel(fields[1])
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		m.setThinking(level)
+		if setting := thinking.Resolve(m.currentModel, m.thinkingLevel); m.err == nil && setting.Note != "" {
+			m.addSystemMessage(fmt.Sprintf("%s; using %s", setting.Note, setting.Level))
+		}
+
+	case "/pin":
+		for _, path := range fields[1:] {
+			path = filepath.Clean(path)
+			resolved, err := m.toolExecutor.ResolvePath(path)
+			if err == nil {
+				var info os.FileInfo
+				if info, err = os.Stat(resolved); err == nil && info.IsDir() {
+					err = fmt.Errorf("%s is a directory", path)
+				}
+			}
+			if err != nil {
+				m.err = err
+				return nil
+			}
+			if !slices.Contains(m.pinned, path) {
+				m.pinned = append(m.pinned, path)
+			}
+		}
+		if len(m.pinned) == 0 {
+			m.addSystemMessage("No files are pinned. Pin one with /pin <path>")
+			return nil
+		}
+		m.addSystemMessage("Pinned files: " + strings.Join(m.pinned, ", "))
+
+	case "/set":
+		if len(fields) == 1 {
+			current := cmp.Or(m.generationParams(m.currentModel).String(), "all at the model's defaults")
+			m.addSystemMessage(fmt.Sprintf("Generation parameters for %s: %s\nSet one with /set <name> <value> or reset it with /set <name> default (names: %s)", m.currentModel, current, strings.Join(params.Names, ", ")))
+			return nil
+		}
+		name := fields[1]
+		var err error
+		if len(fields) == 3 && fields[2] == "default" {
+			err = m.sessionParams.Unset(name)
+		} else {
+			err = m.sessionParams.Set(name, fields[2:])
+		}
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		m.addSystemMessage(fmt.Sprintf("Generation parameters for %s: %s", m.currentModel, cmp.Or(m.generationParams(m.currentModel).String(), "all at the model's defaults")))
+
+	case "/attach":
+		for _, path := range fields[1:] {
+			a, err := attach.Load(m.toolExecutor, filepath.Clean(path))
+			if err == nil {
+				err = m.checkAttachment(a)
+			}
+			if err == nil {
+				err = attach.CheckTotal(append(slices.Clone(m.attachments), a), m.conversation)
+			}
+			if err != nil {
+				m.err = err
+				return nil
+			}
+			m.attachments = append(m.attachments, a)
+		}
+		if len(m.attachments) == 0 {
+			m.addSystemMessage("Nothing is attached. Attach files with /attach <path>, or mention them in a message as @path")
+		}
+
+	case "/detach":
+		m.attachments = nil
+
+	case "/memory":
+		if len(fields) > 1 && fields[1] == "reload" {
+			m.loadInstructions()
+			if m.err != nil {
+				return nil
+			}
+		}
+		m.addSystemMessage(describeInstructions(m.instructions))
+
+	case "/unpin":
+		if len(fields) < 2 {
+			m.err = fmt.Errorf("usage: /unpin <path>...")
+			return nil
+		}
+		for _, path := range fields[1:] {
+			i := slices.Index(m.pinned, filepath.Clean(path))
+			if i < 0 {
+				m.err = fmt.Errorf("%s is not pinned", path)
+				return nil
+			}
+			m.pinned = slices.Delete(m.pinned, i, i+1)
+		}
+		m.addSystemMessage("Unpinned " + strings.Join(fields[1:], ", "))
+
+	default:
+		m.err = fmt.Errorf("unknown command: %s (available: /undo, /rewind <turn>, /compact, /thinking <level>, /set, /attach, /detach, /pin, /unpin, /memory)", fields[0])
+	}
+	return nil
+}
+
+// describeInstructions lists the loaded instruction files for /memory
+func describeInstructions(files []instructions.File) string {
+	if len(files) == 0 {
+		return fmt.Sprintf("No instruction files loaded. Add %s to the project or to %s.", strings.Join(instructions.FileNames, " or "), config.Dir())
+	}
+	var sb strings.Builder
+	sb.WriteString("Instruction files, in the order they are merged (later ones take precedence):")
+	for i, file := range files {
+		fmt.Fprintf(&sb, "\n  %d. %s (%s, %d lines)", i+1, file.Rel, file.Scope, strings.Count(file.Content, "\n")+1)
+		for _, imported := range file.Imports {
+			fmt.Fprintf(&sb, "\n       imports %s", imported)
+		}
+	}
+	return sb.String()
+}
+
+// addSystemMessage adds an informational note to the transcript. It is not sent to the model.
+func (m *model) addSystemMessage(content string) {
+	m.messages = append(m.messages, message{role: "system", content: content})
+}
+
 func (m model) renderMessages() string {
 	if len(m.messages) == 0 {
 		return infoStyle.Render("Start a conversation with Gemini. Type your message and press Enter.\nGemini can read files - try asking about files in your project!")
@@ -534,16 +1526,19 @@ func (m model) renderMessages() string {
 	var sb strings.Builder
 	for _, msg := range m.messages {
 		if msg.role == "user" {
-			sb.WriteString(userStyle.Render("You: "))
+			sb.WriteString(userStyle.Render(fmt.Sprintf("You [#%d]: ", msg.turn)))
 			sb.WriteString(msg.content)
+			if len(msg.attachments) > 0 {
+				sb.WriteString("\n")
+				sb.WriteString(renderChips(msg.attachments))
+			}
+			sb.WriteString("\n\n")
+		} else if msg.role == "system" {
+			sb.WriteString(infoStyle.Render(msg.content))
 			sb.WriteString("\n\n")
 		} else {
-			// Show thinking if present and enabled
-			if msg.thinking != "" && m.showThinking {
-				sb.WriteString(thinkingStyle.Render("Thinking:"))
-				sb.WriteString("\n")
-				sb.WriteString(thinkingStyle.Render(msg.thinking))
-				sb.WriteString("\n\n")
+			if msg.thinking != "" {
+				sb.WriteString(renderThinking(msg.thinking, msg.thinkingTime, m.showThinking, false))
 			}
 			// Show tools used if any
 			if len(msg.toolsUsed) > 0 {
@@ -564,10 +1559,21 @@ func (m model) renderMessages() string {
 			} else {
 				sb.WriteString(msg.content)
 			}
+			if msg.interrupted {
+				if msg.content != "" {
+					sb.WriteString("\n")
+				}
+				sb.WriteString(errorStyle.Render("[interrupted]"))
+			}
 			sb.WriteString("\n\n")
 		}
 	}
 
+	// Show the turn's thinking so far
+	if m.waiting && m.streamThinking != "" {
+		sb.WriteString(renderThinking(m.streamThinking, m.thinkingElapsed(), m.showThinking, !m.thinkingSince.IsZero()))
+	}
+
 	// Show streaming content
 	if m.streaming && m.streamBuffer != "" {
 		if len(m.streamToolsUsed) > 0 {
@@ -581,13 +1587,52 @@ func (m model) renderMessages() string {
 		sb.WriteString(m.streamBuffer)
 		sb.WriteString(infoStyle.Render("..."))
 		sb.WriteString("\n\n")
+	} else if m.toolBatch != nil {
+		sb.WriteString(renderToolProgress(m.toolBatch))
+		if m.awaitingApproval {
+			call := m.toolBatch.calls[m.toolBatch.next]
+			sb.WriteString("\n")
+			sb.WriteString(approvalStyle.Render("Gemini wants to run a tool that needs your approval:"))
+			sb.WriteString("\n")
+			sb.WriteString(toolStyle.Render(describeToolCall(call)))
+			sb.WriteString("\n")
+			sb.WriteString(approvalStyle.Render("Allow? (y/n)"))
+		}
+	} else if m.compacting {
+		sb.WriteString(infoStyle.Render("Compacting the conversation history..."))
 	} else if m.waiting {
 		if len(m.activeTools) > 0 {
 			sb.WriteString(toolStyle.Render("Using tools: "))
 			sb.WriteString(toolStyle.Render(strings.Join(m.activeTools, ", ")))
 			sb.WriteString("\n")
 		}
-		sb.WriteString(infoStyle.Render("Gemini is thinking..."))
+		if m.thinkingSince.IsZero() {
+			sb.WriteString(infoStyle.Render("Gemini is thinking..."))
+		}
+	}
+
+	// Show messages waiting to be delivered
+	for _, p := range m.pending {
+		label := "Queued for after this turn: "
+		if p.steer {
+			label = "Steering (sent with the next tool results): "
+		}
+		sb.WriteString("\n")
+		sb.WriteString(infoStyle.Render(label + p.content))
+	}
+	if len(m.pending) > 0 {
+		sb.WriteString("\n")
+	}
+
+	// Show files waiting to be sent with the next message
+	if len(m.attachments) > 0 {
+		var labels []string
+		for _, a := range m.attachments {
+			labels = append(labels, a.Label())
+		}
+		sb.WriteString(infoStyle.Render("Attached to your next message (/detach to remove): "))
+		sb.WriteString(renderChips(labels))
+		sb.WriteString("\n")
 	}
 
 	if m.err != nil {
@@ -597,76 +1642,258 @@ func (m model) renderMessages() string {
 	return sb.String()
 }
 
+// renderChips shows attachment labels side by side
+func renderChips(labels []string) string {
+	chips := make([]string, len(labels))
+	for i, label := range labels {
+		chips[i] = chipStyle.Render("📎 " + label)
+	}
+	return strings.Join(chips, " ")
+}
+
+// renderThinking shows the model's thoughts as a dimmed block, or as a
+// single line when collapsed. live marks thinking that is still going on.
+func renderThinking(text string, elapsed time.Duration, expanded, live bool) string {
+	label := fmt.Sprintf("Thought for %s", elapsed.Round(time.Second))
+	if live {
+		label = fmt.Sprintf("Thinking... %s", elapsed.Round(time.Second))
+	}
+	if !expanded {
+		return thinkingStyle.Render("▸ "+label+" (Ctrl+H to show)") + "\n\n"
+	}
+	return thinkingStyle.Render("▾ "+label) + "\n" + thinkingStyle.Render(strings.TrimSpace(text)) + "\n\n"
+}
+
+// renderToolProgress lists every call in a batch with its current status
+func renderToolProgress(batch *toolBatch) string {
+	var sb strings.Builder
+	for i, call := range batch.calls {
+		var marker string
+		switch batch.status[i] {
+		case callPending:
+			marker = "  "
+		case callRunning:
+			marker = "… "
+		case callDone:
+			marker = "✓ "
+		case callFailed:
+			marker = "✗ "
+		}
+		sb.WriteString(toolStyle.Render(marker + toolCallLabel(call)))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// toolCallLabel names a call along with its main argument, kept to one line
+func toolCallLabel(call *genai.FunctionCall) string {
+	for _, key := range []string{"path", "pattern", "command", "source"} {
+		arg, ok := call.Args[key].(string)
+		if !ok || arg == "" {
+			continue
+		}
+		arg, _, _ = strings.Cut(arg, "\n")
+		if len(arg) > 60 {
+			arg = arg[:57] + "..."
+		}
+		return call.Name + " " + arg
+	}
+	return call.Name
+}
+
+// describeToolCall returns a short human-readable summary of a function call
+func describeToolCall(call *genai.FunctionCall) string {
+	switch call.Name {
+	case "run_shell_command":
+		command, _ := call.Args["command"].(string)
+		return "$ " + command
+	case "delete_file":
+		path, _ := call.Args["path"].(string)
+		if recursive, _ := call.Args["recursive"].(bool); recursive {
+			return fmt.Sprintf("Delete %s and everything in it", path)
+		}
+		return "Delete " + path
+	case "move_path":
+		source, _ := call.Args["source"].(string)
+		destination, _ := call.Args["destination"].(string)
+		return fmt.Sprintf("Move %s -> %s", source, destination)
+	default:
+		args, err := json.Marshal(call.Args)
+		if err != nil {
+			return call.Name
+		}
+		return fmt.Sprintf("%s %s", call.Name, args)
+	}
+}
+
 func (m model) View() string {
 	if !m.ready {
 		return "Initializing..."
 	}
 
 	// Build status bar
-	modelStatus := statusStyle.Render(m.currentModel)
-	thinkingStatus := statusStyle.Render("Thinking: OFF")
-	if m.thinkingEnabled {
-		thinkingStatus = statusActiveStyle.Render("Thinking: ON")
+	statusBar := statusStyle.Render(m.currentModel)
+	// OpenAI-compatible servers ignore the thinking level, so it isn't shown for them
+	if m.provider.Name() != "openai" {
+		setting := thinking.Resolve(m.currentModel, m.thinkingLevel)
+		thinkingStatus := statusStyle.Render("Thinking: " + setting.String())
+		if setting.Level != m.thinkingLevel {
+			thinkingStatus = statusWarningStyle.Render(fmt.Sprintf("Thinking: %s (%s unsupported)", setting, m.thinkingLevel))
+		} else if setting.Level != thinking.Off {
+			thinkingStatus = statusActiveStyle.Render("Thinking: " + setting.String())
+		}
+		statusBar += " " + thinkingStatus
+	}
+	if generation := m.generationParams(m.currentModel).String(); generation != "" {
+		statusBar += " " + statusActiveStyle.Render(generation)
+	}
+	if m.usage.Session().Total() > 0 {
+		statusBar += " " + statusStyle.Render(m.usage.Status())
+	}
+	if wait := time.Until(m.retryUntil); wait > 0 && m.waiting {
+		retry := fmt.Sprintf("Retrying in %ds (%s)", int(wait.Round(time.Second)/time.Second), m.retryLabel)
+		statusBar += " " + statusWarningStyle.Render(retry)
 	}
-	statusBar := fmt.Sprintf("%s %s", modelStatus, thinkingStatus)
 
 	header := titleStyle.Render("Gemini TUI") + "  " + statusBar
 	footer := m.textarea.View()
-	help := infoStyle.Render("Enter: send | Ctrl+T: thinking | Ctrl+G: model | Ctrl+H: hide thinking | Esc: quit")
+	help := infoStyle.Render("Enter: send | Ctrl+T: thinking | Ctrl+G: model | Ctrl+H: show/hide thinking | Ctrl+C twice: quit")
+	if m.quitPending {
+		help = infoStyle.Render("Press Ctrl+C again to quit")
+	} else if m.compacting {
+		help = infoStyle.Render("Esc: cancel | Ctrl+C twice: quit")
+	} else if m.awaitingApproval {
+		help = infoStyle.Render("y: allow | n: deny | Esc: interrupt | Ctrl+C twice: quit")
+	} else if m.waiting {
+		help = infoStyle.Render("Enter: steer | Ctrl+Q: queue | Esc: interrupt | Ctrl+C twice: quit")
+	}
 
 	return fmt.Sprintf("%s\n%s\n%s\n%s", header, m.viewport.View(), footer, help)
 }
 
+// printHelp describes the command line, the environment each backend needs
+// and the keyboard shortcuts
+func printHelp() {
+	fmt.Println("gemini-tui - A terminal UI for Google Gemini")
+	fmt.Printf("Version: %s\n\n", version)
+	fmt.Println("Usage: gemini-tui [options]")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --version, -v          Show version")
+	fmt.Println("  --help, -h             Show this help")
+	fmt.Println("  --provider <type>      Model backend: gemini (default), vertexai or openai")
+	fmt.Println("  --project <id>         Google Cloud project for Vertex AI")
+	fmt.Println("  --location <region>    Google Cloud location for Vertex AI (default: global)")
+	fmt.Println("  --credentials <file>   Service account key file for Vertex AI")
+	fmt.Println()
+	fmt.Println("Environment:")
+	fmt.Println("  Gemini API (gemini):")
+	fmt.Println("    GOOGLE_API_KEY                  Required. Your Gemini API key")
+	fmt.Println("  Vertex AI (vertexai):")
+	fmt.Println("    GOOGLE_CLOUD_PROJECT            Project, if not given by --project or the config")
+	fmt.Println("    GOOGLE_CLOUD_LOCATION           Location, if not given by --location or the config")
+	fmt.Println("    GOOGLE_APPLICATION_CREDENTIALS  Service account key file, if not given by --credentials")
+	fmt.Println("                                    or the config; otherwise the credentials from")
+	fmt.Println("                                    `gcloud auth application-default login` are used")
+	fmt.Println("    GOOGLE_GENAI_USE_VERTEXAI=true  Use Vertex AI when no provider is configured")
+	fmt.Println("  OpenAI-compatible servers (openai):")
+	fmt.Println("    The variable named by provider.api_key_env, if the server needs a key")
+	fmt.Println()
+	fmt.Printf("Config file: %s\n", config.Path())
+	fmt.Println()
+	fmt.Println("Available models (cycle with Ctrl+G):")
+	for _, m := range availableModels {
+		fmt.Printf("  - %s\n", m)
+	}
+	fmt.Println()
+	fmt.Println("Keyboard shortcuts:")
+	fmt.Println("  Enter      Send message")
+	fmt.Println("  Ctrl+T     Cycle thinking levels: off, low, medium, high, dynamic")
+	fmt.Println("  Ctrl+G     Cycle models")
+	fmt.Println("  Ctrl+H     Toggle thinking display")
+	fmt.Println("  Enter      While Gemini works: steer it at the next tool step")
+	fmt.Println("  Ctrl+Q     While Gemini works: queue a message for after the turn")
+	fmt.Println("  Esc        Interrupt the current response and any running tools")
+	fmt.Println("  Ctrl+C     Interrupt; press twice to quit")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  /undo           Revert the file changes from the last turn that made any")
+	fmt.Println("  /rewind <turn>  Revert all file changes from turn <turn> onwards")
+	fmt.Println("  /compact        Summarize older turns to free up context")
+	fmt.Println("  /thinking <level>  Set the thinking level: off, low, medium, high or dynamic")
+	fmt.Println("  /set [name value]  Show or set a generation parameter for this session, e.g.")
+	fmt.Println("                     /set temperature 0.2; /set temperature default resets it")
+	fmt.Println("  /pin [path...]  Send files with every request, or list the pinned files")
+	fmt.Println("  /unpin <path>   Stop sending a pinned file")
+	fmt.Println("  /attach <path>  Send a file (image, PDF, audio, video or text) with the next message;")
+	fmt.Println("                  mentioning @path in a message does the same")
+	fmt.Println("  /detach         Remove the attached files")
+	fmt.Println("  /memory         List the loaded GEMINI.md and AGENTS.md files; /memory reload re-reads them")
+	fmt.Println()
+	fmt.Println("Get an API key at: https://aistudio.google.com/apikey")
+}
+
 func main() {
-	// Handle --version flag
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "--version", "-v", "version":
-			fmt.Printf("gemini-tui %s\n", version)
-			os.Exit(0)
-		case "--help", "-h", "help":
-			fmt.Println("gemini-tui - A terminal UI for Google Gemini")
-			fmt.Printf("Version: %s\n\n", version)
-			fmt.Println("Usage: gemini-tui [options]")
-			fmt.Println()
-			fmt.Println("Options:")
-			fmt.Println("  --version, -v    Show version")
-			fmt.Println("  --help, -h       Show this help")
-			fmt.Println()
-			fmt.Println("Environment:")
-			fmt.Println("  GOOGLE_API_KEY   Required. Your Gemini API key")
-			fmt.Println()
-			fmt.Println("Available models (cycle with Ctrl+G):")
-			for _, m := range availableModels {
-				fmt.Printf("  - %s\n", m)
-			}
-			fmt.Println()
-			fmt.Println("Keyboard shortcuts:")
-			fmt.Println("  Enter      Send message")
-			fmt.Println("  Ctrl+T     Toggle thinking mode")
-			fmt.Println("  Ctrl+G     Cycle models")
-			fmt.Println("  Ctrl+H     Toggle thinking display")
-			fmt.Println("  Esc        Quit")
-			fmt.Println()
-			fmt.Println("Get an API key at: https://aistudio.google.com/apikey")
+	flags := flag.NewFlagSet("gemini-tui", flag.ContinueOnError)
+	flags.Usage = printHelp
+	var showVersion bool
+	flags.BoolVar(&showVersion, "version", false, "")
+	flags.BoolVar(&showVersion, "v", false, "")
+	providerType := flags.String("provider", "", "")
+	project := flags.String("project", "", "")
+	location := flags.String("location", "", "")
+	credentials := flags.String("credentials", "", "")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
 			os.Exit(0)
 		}
+		os.Exit(2)
+	}
+	switch flags.Arg(0) {
+	case "version":
+		showVersion = true
+	case "help":
+		printHelp()
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Printf("gemini-tui %s\n", version)
+		os.Exit(0)
 	}
 
-	apiKey := os.Getenv("GOOGLE_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Error: GOOGLE_API_KEY environment variable is not set")
-		fmt.Println("Get your API key from: https://aistudio.google.com/apikey")
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	if cfg.Thinking.Level != "" {
+		if _, err := thinking.ParseLevel(cfg.Thinking.Level); err != nil {
+			fmt.Printf("Error in config thinking.level: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
+	// Command-line flags take precedence over the config file
+	if *providerType != "" {
+		cfg.Provider.Type = *providerType
+	}
+	if *project != "" {
+		cfg.Provider.Project = *project
+	}
+	if *location != "" {
+		cfg.Provider.Location = *location
+	}
+	if *credentials != "" {
+		cfg.Provider.CredentialsFile = *credentials
+	}
+
+	prov, err := newProvider(cfg.Provider)
 	if err != nil {
-		fmt.Printf("Error creating Gemini client: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if fallback := cfg.Retry.FallbackModel; fallback != "" && !slices.Contains(prov.Models(), fallback) {
+		fmt.Printf("Error in config retry.fallback_model: %s is not one of %s\n", fallback, strings.Join(prov.Models(), ", "))
 		os.Exit(1)
 	}
 
@@ -682,14 +1909,113 @@ func main() {
 		fmt.Printf("Error creating tool executor: %v\n", err)
 		os.Exit(1)
 	}
+	for name, timeout := range cfg.Tools.Timeouts {
+		if err := executor.SetTimeout(name, timeout); err != nil {
+			fmt.Printf("Error in config tools.timeouts: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	p := tea.NewProgram(
-		initialModel(client, executor),
+		initialModel(prov, executor, cfg),
 		tea.WithAltScreen(),
 	)
 
-	if _, err := p.Run(); err != nil {
+	finalModel, err := p.Run()
+	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
 		os.Exit(1)
 	}
+	if cacher, ok := prov.(provider.Cacher); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cacher.ReleaseCaches(ctx)
+		cancel()
+	}
+	if m, ok := finalModel.(model); ok {
+		fmt.Print(m.usage.Summary())
+	}
+}
+
+// newProvider creates the model backend selected in the config
+func newProvider(cfg config.ProviderConfig) (provider.Provider, error) {
+	models := availableModels
+	if len(cfg.Models) > 0 {
+		models = cfg.Models
+	}
+
+	providerType := cfg.Type
+	if providerType == "" {
+		providerType = "gemini"
+		if v, _ := strconv.ParseBool(os.Getenv("GOOGLE_GENAI_USE_VERTEXAI")); v {
+			providerType = "vertexai"
+		}
+	}
+
+	switch providerType {
+	case "gemini":
+		apiKey := os.Getenv("GOOGLE_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("GOOGLE_API_KEY environment variable is not set\nGet your API key from: https://aistudio.google.com/apikey")
+		}
+		client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+			APIKey:  apiKey,
+			Backend: genai.BackendGeminiAPI,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating Gemini client: %w", err)
+		}
+		return provider.NewGemini(client, models), nil
+
+	case "vertexai":
+		return newVertexProvider(cfg, models)
+
+	case "openai":
+		if len(cfg.Models) == 0 {
+			return nil, fmt.Errorf("provider.models in %s must list at least one model for the openai provider", config.Path())
+		}
+		var apiKey string
+		if cfg.APIKeyEnv != "" {
+			apiKey = os.Getenv(cfg.APIKeyEnv)
+			if apiKey == "" {
+				return nil, fmt.Errorf("%s environment variable is not set", cfg.APIKeyEnv)
+			}
+		}
+		return provider.NewOpenAI(cfg.BaseURL, apiKey, cfg.Models), nil
+
+	default:
+		return nil, fmt.Errorf("unknown provider type %q (use gemini, vertexai or openai)", providerType)
+	}
+}
+
+// newVertexProvider creates a Gemini provider that goes through Vertex AI,
+// authenticating with a service account key file or Application Default
+// Credentials
+func newVertexProvider(cfg config.ProviderConfig, models []string) (provider.Provider, error) {
+	project := cmp.Or(cfg.Project, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	if project == "" {
+		return nil, fmt.Errorf("Vertex AI needs a Google Cloud project: use --project, set provider.project in %s, or set GOOGLE_CLOUD_PROJECT", config.Path())
+	}
+	location := cmp.Or(cfg.Location, os.Getenv("GOOGLE_CLOUD_LOCATION"), os.Getenv("GOOGLE_CLOUD_REGION"), "global")
+
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+		CredentialsFile: cfg.CredentialsFile,
+	})
+	if err != nil {
+		if cfg.CredentialsFile != "" {
+			return nil, fmt.Errorf("loading credentials from %s: %w", cfg.CredentialsFile, err)
+		}
+		return nil, fmt.Errorf("no Google Cloud credentials found: use --credentials, set GOOGLE_APPLICATION_CREDENTIALS, or run `gcloud auth application-default login` (%w)", err)
+	}
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     project,
+		Location:    location,
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating Vertex AI client: %w", err)
+	}
+	return provider.NewGemini(client, models), nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	"google.golang.org/genai"
+
+	"github.com/haljac/gemini-tui/internal/config"
+	"github.com/haljac/gemini-tui/tools"
+)
+
+type stubProvider struct{ name string }
+
+func (p stubProvider) Name() string   { return p.name }
+func (stubProvider) Models() []string { return []string{"stub-model"} }
+func (stubProvider) GenerateContentStream(context.Context, string, []*genai.Content, *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(func(*genai.GenerateContentResponse, error) bool) {}
+}
+
+func newTestModel(t *testing.T, providerName string) model {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	executor, err := tools.NewExecutor(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return initialModel(stubProvider{name: providerName}, executor, config.DefaultConfig())
+}
+
+func TestStreamErrorStopsThinking(t *testing.T) {
+	m := newTestModel(t, "gemini")
+	m.waiting = true
+	m.streaming = true
+
+	updated, _ := m.Update(streamThoughtMsg{chunk: "considering"})
+	m = updated.(model)
+	if m.thinkingSince.IsZero() || !m.thinkingTicking {
+		t.Fatal("thinking timer did not start")
+	}
+
+	updated, _ = m.Update(streamErrorMsg{err: errors.New("connection reset")})
+	m = updated.(model)
+	if !m.thinkingSince.IsZero() {
+		t.Error("thinking timer still running after the turn failed")
+	}
+	if m.streamThinking != "" {
+		t.Errorf("streamThinking = %q, want empty", m.streamThinking)
+	}
+
+	updated, cmd := m.Update(thinkingTickMsg{})
+	m = updated.(model)
+	if cmd != nil || m.thinkingTicking {
+		t.Error("thinking tick kept running after the turn failed")
+	}
+}
+
+func TestThinkingLevelFixedForOpenAI(t *testing.T) {
+	m := newTestModel(t, "openai")
+	level := m.thinkingLevel
+	m.setThinking(level.Next())
+	if m.err == nil || m.thinkingLevel != level {
+		t.Errorf("thinking level changed to %s for an OpenAI-compatible server", m.thinkingLevel)
+	}
+}
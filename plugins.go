@@ -0,0 +1,12 @@
+//go:build plugins
+
+package main
+
+// This file is the hook for compiling in custom tools without changing
+// main.go. Blank-import each package that calls tools.Register from its init
+// function, then build with the plugins tag:
+//
+//	go build -tags plugins
+import (
+// _ "example.com/mytools"
+)
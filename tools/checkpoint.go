@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxSnapshotSize caps how much file content a single mutation may snapshot.
+// Larger deletions and moves are refused rather than made unrecoverable.
+const maxSnapshotSize = 20 * 1024 * 1024
+
+// fileSnapshot records the state of a path before the agent first changed it in a turn
+type fileSnapshot struct {
+	path    string
+	existed bool
+	mode    fs.FileMode
+	content []byte // File contents for regular files
+	target  string // Link target for symlinks
+}
+
+// checkpoint holds the snapshots taken during one conversation turn
+type checkpoint struct {
+	turn      int
+	snapshots []fileSnapshot
+	seen      map[string]bool // Snapshotted paths; true once everything beneath them is recorded too
+}
+
+// checkpoints records file state before each mutation so that changes can be undone
+type checkpoints struct {
+	mu      sync.Mutex
+	turn    int
+	history []*checkpoint
+}
+
+// BeginTurn starts a new conversation turn. Every file change made by tools
+// until the next call is grouped under this turn for Undo and Rewind.
+func (e *Executor) BeginTurn(turn int) {
+	e.checkpoints.mu.Lock()
+	defer e.checkpoints.mu.Unlock()
+	e.checkpoints.turn = turn
+}
+
+// Turns returns the turns that changed files and can still be undone, oldest first
+func (e *Executor) Turns() []int {
+	e.checkpoints.mu.Lock()
+	defer e.checkpoints.mu.Unlock()
+
+	var turns []int
+	for _, cp := range e.checkpoints.history {
+		turns = append(turns, cp.turn)
+	}
+	return turns
+}
+
+// Undo restores every file changed during the most recent turn that changed
+// files, and returns that turn and the restored paths relative to the
+// working directory
+func (e *Executor) Undo() (int, []string, error) {
+	e.checkpoints.mu.Lock()
+	defer e.checkpoints.mu.Unlock()
+
+	history := e.checkpoints.history
+	if len(history) == 0 {
+		return 0, nil, fmt.Errorf("no file changes to undo")
+	}
+
+	cp := history[len(history)-1]
+	restored, err := e.restore(cp)
+	if err != nil {
+		return cp.turn, restored, err
+	}
+	e.checkpoints.history = history[:len(history)-1]
+	return cp.turn, restored, nil
+}
+
+// Rewind restores files to how they were before the given turn, undoing that
+// turn and every later one, and returns the restored paths relative to the
+// working directory
+func (e *Executor) Rewind(turn int) ([]string, error) {
+	e.checkpoints.mu.Lock()
+	defer e.checkpoints.mu.Unlock()
+
+	history := e.checkpoints.history
+	var restored []string
+	for len(history) > 0 && history[len(history)-1].turn >= turn {
+		cp := history[len(history)-1]
+		paths, err := e.restore(cp)
+		restored = append(restored, paths...)
+		if err != nil {
+			e.checkpoints.history = history
+			return restored, err
+		}
+		history = history[:len(history)-1]
+	}
+	e.checkpoints.history = history
+
+	if len(restored) == 0 {
+		return nil, fmt.Errorf("no file changes from turn %d onwards", turn)
+	}
+	return dedupe(restored), nil
+}
+
+// snapshot records the current state of path before it is modified. With
+// tree set, everything beneath a directory is recorded as well. Only the
+// first snapshot of a path in a turn is kept, since that is the state to
+// return to.
+func (e *Executor) snapshot(path string, tree bool) error {
+	e.checkpoints.mu.Lock()
+	defer e.checkpoints.mu.Unlock()
+
+	cp := e.currentCheckpoint()
+	if cp.covers(path) {
+		return nil
+	}
+
+	// A missing path is recorded at its topmost missing ancestor, so that
+	// restoring also removes any directories created to hold it. Ancestors
+	// that were already snapshotted this turn (e.g. deleted and now being
+	// recreated) are restored from that snapshot instead.
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		missing := path
+		for {
+			parent := filepath.Dir(missing)
+			if parent == missing || parent == e.workingDir {
+				break
+			}
+			if _, err := os.Lstat(parent); err == nil {
+				break
+			}
+			if _, ok := cp.seen[parent]; ok {
+				break
+			}
+			missing = parent
+		}
+		cp.add(fileSnapshot{path: missing}, true)
+		return nil
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.IsDir() && !tree {
+		cp.add(fileSnapshot{path: path, existed: true, mode: info.Mode()}, false)
+		return nil
+	}
+
+	var snapshots []fileSnapshot
+	total := 0
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if cp.covers(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		snap := fileSnapshot{path: p, existed: true, mode: info.Mode()}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			snap.target, err = os.Readlink(p)
+		case info.Mode().IsRegular():
+			total += int(info.Size())
+			if total > maxSnapshotSize {
+				return fmt.Errorf("too much data to snapshot for undo (over %d bytes)", maxSnapshotSize)
+			}
+			snap.content, err = os.ReadFile(p)
+		}
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snap)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range snapshots {
+		cp.add(snap, true)
+	}
+	return nil
+}
+
+// currentCheckpoint returns the checkpoint for the current turn, creating it if needed
+func (e *Executor) currentCheckpoint() *checkpoint {
+	history := e.checkpoints.history
+	if len(history) > 0 && history[len(history)-1].turn == e.checkpoints.turn {
+		return history[len(history)-1]
+	}
+	cp := &checkpoint{turn: e.checkpoints.turn, seen: make(map[string]bool)}
+	e.checkpoints.history = append(history, cp)
+	return cp
+}
+
+// add records a snapshot unless the path already has one; complete marks
+// that everything beneath the path has been recorded
+func (cp *checkpoint) add(snap fileSnapshot, complete bool) {
+	if _, ok := cp.seen[snap.path]; !ok {
+		cp.snapshots = append(cp.snapshots, snap)
+	}
+	cp.seen[snap.path] = cp.seen[snap.path] || complete
+}
+
+// covers reports whether path and everything beneath it, or a directory
+// containing it that did not exist at the start of the turn, has already
+// been snapshotted
+func (cp *checkpoint) covers(path string) bool {
+	if cp.seen[path] {
+		return true
+	}
+	for _, snap := range cp.snapshots {
+		if !snap.existed && strings.HasPrefix(path, snap.path+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// restore puts every path in a checkpoint back to its recorded state
+func (e *Executor) restore(cp *checkpoint) ([]string, error) {
+	var restored []string
+
+	// Remove paths that didn't exist first, newest first, then recreate the
+	// rest, parents before children
+	for i := len(cp.snapshots) - 1; i >= 0; i-- {
+		snap := cp.snapshots[i]
+		if snap.existed {
+			continue
+		}
+		if err := os.RemoveAll(snap.path); err != nil {
+			return restored, fmt.Errorf("failed to remove %s: %w", snap.path, err)
+		}
+		restored = append(restored, e.relativePath(snap.path))
+	}
+
+	existing := make([]fileSnapshot, 0, len(cp.snapshots))
+	for _, snap := range cp.snapshots {
+		if snap.existed {
+			existing = append(existing, snap)
+		}
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		return len(existing[i].path) < len(existing[j].path)
+	})
+
+	for _, snap := range existing {
+		if err := restoreSnapshot(snap); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", snap.path, err)
+		}
+		if !snap.mode.IsDir() {
+			restored = append(restored, e.relativePath(snap.path))
+		}
+	}
+
+	return dedupe(restored), nil
+}
+
+// restoreSnapshot recreates a single file, directory or symlink
+func restoreSnapshot(snap fileSnapshot) error {
+	current, err := os.Lstat(snap.path)
+	exists := err == nil
+
+	// Clear whatever is in the way if the type has changed
+	if exists && (current.Mode().Type() != snap.mode.Type()) {
+		if err := os.RemoveAll(snap.path); err != nil {
+			return err
+		}
+		exists = false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(snap.path), 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case snap.mode.IsDir():
+		if !exists {
+			return os.Mkdir(snap.path, snap.mode.Perm())
+		}
+		return nil
+	case snap.mode&fs.ModeSymlink != 0:
+		if exists {
+			if err := os.Remove(snap.path); err != nil {
+				return err
+			}
+		}
+		return os.Symlink(snap.target, snap.path)
+	default:
+		if err := os.WriteFile(snap.path, snap.content, snap.mode.Perm()); err != nil {
+			return err
+		}
+		return os.Chmod(snap.path, snap.mode.Perm())
+	}
+}
+
+// dedupe removes repeated paths while keeping their first-seen order
+func dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	out := paths[:0]
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// relativePath returns path relative to the working directory for display
+func (e *Executor) relativePath(path string) string {
+	rel, err := filepath.Rel(e.workingDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
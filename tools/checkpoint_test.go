@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		args  map[string]any
+		paths []string // Reported by Undo
+		fails bool     // The tool call itself fails
+		check func(t *testing.T, project string)
+	}{
+		{
+			name:  "modify",
+			tool:  "write_file",
+			args:  map[string]any{"path": "src/main.go", "content": "package changed\n"},
+			paths: []string{"src/main.go"},
+			check: func(t *testing.T, project string) {
+				if got := readTestFile(t, filepath.Join(project, "src", "main.go")); got != "package main\n" {
+					t.Errorf("main.go = %q", got)
+				}
+			},
+		},
+		{
+			name:  "create",
+			tool:  "write_file",
+			args:  map[string]any{"path": "a/b/new.txt", "content": "new\n"},
+			paths: []string{"a"},
+			check: func(t *testing.T, project string) {
+				if _, err := os.Stat(filepath.Join(project, "a")); !os.IsNotExist(err) {
+					t.Error("the directories created for the file were left behind")
+				}
+			},
+		},
+		{
+			name:  "delete",
+			tool:  "delete_file",
+			args:  map[string]any{"path": "src", "recursive": true},
+			paths: []string{"src/main.go"},
+			check: func(t *testing.T, project string) {
+				if got := readTestFile(t, filepath.Join(project, "src", "main.go")); got != "package main\n" {
+					t.Errorf("main.go = %q", got)
+				}
+			},
+		},
+		{
+			name: "failed write",
+			tool: "write_file",
+			// Writing to a directory fails after the snapshot is taken
+			args:  map[string]any{"path": "src", "content": "oops"},
+			fails: true,
+			check: func(t *testing.T, project string) {
+				if got := readTestFile(t, filepath.Join(project, "src", "main.go")); got != "package main\n" {
+					t.Errorf("main.go = %q", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, project, _ := sandbox(t)
+			e.BeginTurn(1)
+			if result := execute(t, e, tt.tool, tt.args); (result["error"] != nil) != tt.fails {
+				t.Fatalf("%s returned %v", tt.tool, result)
+			}
+
+			turn, paths, err := e.Undo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if turn != 1 || !slices.Equal(paths, tt.paths) {
+				t.Errorf("Undo() = %d, %v; want 1, %v", turn, paths, tt.paths)
+			}
+			tt.check(t, project)
+			if _, _, err := e.Undo(); err == nil {
+				t.Error("expected nothing left to undo")
+			}
+		})
+	}
+}
+
+func TestRewindUndoesLaterTurns(t *testing.T) {
+	e, project, _ := sandbox(t)
+	notes := filepath.Join(project, "notes.txt")
+
+	e.BeginTurn(1)
+	execute(t, e, "write_file", map[string]any{"path": "notes.txt", "content": "one\n"})
+	e.BeginTurn(2)
+	execute(t, e, "edit_file", map[string]any{"path": "notes.txt", "old_string": "one", "new_string": "two"})
+	execute(t, e, "write_file", map[string]any{"path": "extra.txt", "content": "extra\n"})
+	e.BeginTurn(3) // Changes nothing
+	e.BeginTurn(4)
+	execute(t, e, "edit_file", map[string]any{"path": "notes.txt", "old_string": "two", "new_string": "four"})
+	execute(t, e, "move_path", map[string]any{"source": "src/main.go", "destination": "main.go"})
+
+	if got := e.Turns(); !slices.Equal(got, []int{1, 2, 4}) {
+		t.Fatalf("Turns() = %v", got)
+	}
+	if _, err := e.Rewind(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestFile(t, notes); got != "one\n" {
+		t.Errorf("notes.txt = %q, want the state after turn 1", got)
+	}
+	if _, err := os.Stat(filepath.Join(project, "extra.txt")); !os.IsNotExist(err) {
+		t.Error("extra.txt was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(project, "main.go")); !os.IsNotExist(err) {
+		t.Error("the moved file was not moved back")
+	}
+	if got := readTestFile(t, filepath.Join(project, "src", "main.go")); got != "package main\n" {
+		t.Errorf("src/main.go = %q", got)
+	}
+	if got := e.Turns(); !slices.Equal(got, []int{1}) {
+		t.Errorf("Turns() after rewind = %v", got)
+	}
+	if _, err := e.Rewind(2); err == nil {
+		t.Error("expected nothing left to rewind")
+	}
+}
@@ -0,0 +1,923 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// maxReadLineLength caps a single line returned by read_file (e.g. minified code)
+const maxReadLineLength = 2000
+
+// readChunkSize is how much of a line read_file holds in memory at once
+const readChunkSize = 64 * 1024
+
+// Executor handles tool execution with security constraints
+type Executor struct {
+	workingDir      string
+	maxFileSize     int64
+	maxResults      int
+	maxReadLines    int
+	maxOutputSize   int
+	shellTimeout    time.Duration
+	maxShellTimeout time.Duration
+	checkpoints     checkpoints
+	registry        *Registry
+	timeouts        map[string]time.Duration // Per-tool overrides of Metadata.Timeout
+}
+
+// NewExecutor creates a new tool executor rooted at the given directory
+func NewExecutor(workingDir string) (*Executor, error) {
+	absDir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	// Compare against the real directory so resolved paths line up with it
+	absDir, err = filepath.EvalSymlinks(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	e := &Executor{
+		workingDir:      absDir,
+		maxFileSize:     100 * 1024,       // 100KB limit
+		maxResults:      100,              // Max glob results
+		maxReadLines:    2000,             // Default and max lines per read_file page
+		maxOutputSize:   32 * 1024,        // Per-stream cap on command output
+		shellTimeout:    2 * time.Minute,  // Default command timeout
+		maxShellTimeout: 10 * time.Minute, // Upper bound for requested timeouts
+		registry:        NewRegistry(),
+		timeouts:        make(map[string]time.Duration),
+	}
+
+	for _, t := range append(builtinTools, registeredExtensions()...) {
+		if err := e.registry.Add(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// Execute runs a tool by name with the given arguments and waits for it to
+// finish. The tool's context ends when ctx is cancelled or the tool's timeout
+// expires; a tool that stops because of that returns the context's error,
+// and its partial result is then marked as timed out or cancelled. A tool
+// that completes its work is reported as it returned.
+func (e *Executor) Execute(ctx context.Context, name string, args map[string]any) (map[string]any, error) {
+	t, ok := e.registry.Get(name)
+	if !ok {
+		return map[string]any{"error": fmt.Sprintf("unknown tool: %s", name)}, nil
+	}
+
+	// Reject malformed calls up front so handlers only see valid input
+	if problems := validateArgs(t.Declaration().Parameters, args); len(problems) > 0 {
+		return map[string]any{
+			"error":    fmt.Sprintf("invalid arguments for %s: %d problem(s) found, nothing was done", name, len(problems)),
+			"problems": problems,
+		}, nil
+	}
+
+	timeout := e.Timeout(name)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := t.Execute(ctx, e, args)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		if result == nil {
+			result = map[string]any{}
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			result["error"] = fmt.Sprintf("%s timed out after %s", name, timeout)
+			result["timed_out"] = true
+		} else {
+			result["error"] = fmt.Sprintf("%s was cancelled", name)
+			result["cancelled"] = true
+		}
+		return result, nil
+	}
+	return result, err
+}
+
+// Timeout returns how long a single call to the named tool may run
+func (e *Executor) Timeout(name string) time.Duration {
+	if d, ok := e.timeouts[name]; ok {
+		return d
+	}
+	if t, ok := e.registry.Get(name); ok && t.Metadata().Timeout > 0 {
+		return t.Metadata().Timeout
+	}
+	return DefaultTimeout
+}
+
+// SetTimeout overrides the timeout of the named tool. A zero or negative
+// duration restores the tool's default.
+func (e *Executor) SetTimeout(name string, d time.Duration) error {
+	if _, ok := e.registry.Get(name); !ok {
+		return fmt.Errorf("unknown tool: %s", name)
+	}
+	if d <= 0 {
+		delete(e.timeouts, name)
+		return nil
+	}
+	e.timeouts[name] = d
+	return nil
+}
+
+// Registry returns the tools available to this executor
+func (e *Executor) Registry() *Registry {
+	return e.registry
+}
+
+// Register adds a tool to this executor only
+func (e *Executor) Register(t Tool) error {
+	return e.registry.Add(t)
+}
+
+// WorkingDir returns the real path of the directory tools are confined to
+func (e *Executor) WorkingDir() string {
+	return e.workingDir
+}
+
+// ResolvePath resolves a tool path argument the same way the built-in tools
+// do, failing if its real location is outside the working directory
+func (e *Executor) ResolvePath(path string) (string, error) {
+	return e.resolvePath(path)
+}
+
+// Snapshot saves the current state of a path so that a change a custom tool
+// is about to make can be reverted with /undo
+func (e *Executor) Snapshot(path string) error {
+	return e.snapshot(path, true)
+}
+
+// readFile reads a window of lines from a file and returns them line-numbered
+func (e *Executor) readFile(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, ok := args["path"].(string)
+	if !ok || pathArg == "" {
+		return map[string]any{"error": "path is required"}, nil
+	}
+
+	offset, _ := numberArg(args, "offset")
+	if offset < 1 {
+		offset = 1
+	}
+	limit, _ := numberArg(args, "limit")
+	if limit <= 0 || limit > e.maxReadLines {
+		limit = e.maxReadLines
+	}
+
+	// Security check
+	fullPath, err := e.resolvePath(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("file not found: %s", pathArg)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if info.IsDir() {
+		return map[string]any{"error": "path is a directory, use list_directory instead"}, nil
+	}
+
+	// Check for binary file
+	if e.isBinaryFile(fullPath) {
+		return map[string]any{
+			"error": "file appears to be binary",
+			"path":  fullPath,
+			"size":  info.Size(),
+		}, nil
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	defer f.Close()
+
+	// Read up to the end of the page, a chunk at a time so that neither a
+	// huge file nor a huge line is held in memory. The page is also cut
+	// short once it reaches maxFileSize bytes.
+	lineEnd := true // Whether the last token reached the end of its line
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, readChunkSize), readChunkSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			lineEnd = true
+			return i + 1, data[:i], nil
+		}
+		if len(data) >= readChunkSize || atEOF && len(data) > 0 {
+			lineEnd = atEOF
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+
+	var content strings.Builder
+	line := 0        // Lines reached so far
+	midLine := false // The last token was only the start of a line
+	more := false    // There are lines after the page
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		continuing := midLine
+		midLine = !lineEnd
+		if continuing {
+			continue
+		}
+		line++
+		if line < offset {
+			continue
+		}
+		if line >= offset+limit {
+			more = true
+			break
+		}
+
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(text) > maxReadLineLength || midLine {
+			text = cutRunes(text, maxReadLineLength) + "... [line truncated]"
+		}
+		numbered := fmt.Sprintf("%6d\t%s\n", line, text)
+		if int64(content.Len()+len(numbered)) > e.maxFileSize && content.Len() > 0 {
+			more = true
+			break
+		}
+		content.WriteString(numbered)
+	}
+	if err := scanner.Err(); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if offset > line && line > 0 {
+		return map[string]any{
+			"error":       fmt.Sprintf("offset %d is past the end of the file (%d lines)", offset, line),
+			"path":        fullPath,
+			"total_lines": line,
+		}, nil
+	}
+
+	endLine := max(offset-1, line)
+	if more {
+		endLine = line - 1
+	}
+	result := map[string]any{
+		"path":       fullPath,
+		"content":    content.String(),
+		"size":       info.Size(),
+		"start_line": offset,
+		"end_line":   endLine,
+		"truncated":  more,
+	}
+	if more {
+		result["next_offset"] = endLine + 1
+	} else {
+		result["total_lines"] = line
+	}
+
+	return result, nil
+}
+
+// cutRunes shortens s to at most n bytes without splitting a UTF-8 sequence
+func cutRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// listDirectory lists contents of a directory
+func (e *Executor) listDirectory(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, _ := args["path"].(string)
+	if pathArg == "" {
+		pathArg = "."
+	}
+
+	// Security check
+	fullPath, err := e.resolvePath(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("directory not found: %s", pathArg)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if !info.IsDir() {
+		return map[string]any{"error": "path is not a directory"}, nil
+	}
+
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	var items []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		items = append(items, name)
+	}
+
+	return map[string]any{
+		"path":  fullPath,
+		"items": items,
+		"count": len(items),
+	}, nil
+}
+
+// globSearch finds files matching a glob pattern
+func (e *Executor) globSearch(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return map[string]any{"error": "pattern is required"}, nil
+	}
+
+	// Use doublestar for ** support. Symlinked directories aren't walked, as
+	// they may lead outside the working directory or loop back on themselves.
+	matches, err := doublestar.Glob(contextFS{ctx, os.DirFS(e.workingDir)}, pattern, doublestar.WithNoFollow())
+	if ctx.Err() != nil {
+		// The walk may have skipped what it couldn't open rather than failing
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return map[string]any{"error": fmt.Sprintf("invalid pattern: %s", err.Error())}, nil
+	}
+
+	// A symlink named before the pattern's first wildcard is still followed,
+	// so drop anything whose real path escapes the working directory
+	allowed := matches[:0]
+	for _, match := range matches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := e.resolvePath(filepath.FromSlash(match)); err == nil {
+			allowed = append(allowed, match)
+		}
+	}
+	matches = allowed
+
+	// Limit results
+	truncated := false
+	if len(matches) > e.maxResults {
+		matches = matches[:e.maxResults]
+		truncated = true
+	}
+
+	return map[string]any{
+		"pattern":   pattern,
+		"matches":   matches,
+		"count":     len(matches),
+		"truncated": truncated,
+	}, nil
+}
+
+// writeFile writes content to a file
+func (e *Executor) writeFile(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, ok := args["path"].(string)
+	if !ok || pathArg == "" {
+		return map[string]any{"error": "path is required"}, nil
+	}
+
+	content, ok := args["content"].(string)
+	if !ok {
+		return map[string]any{"error": "content is required"}, nil
+	}
+
+	// Security check
+	fullPath, err := e.resolvePath(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	// Check if file size would exceed limit
+	if int64(len(content)) > e.maxFileSize*10 { // Allow larger writes than reads
+		return map[string]any{
+			"error": fmt.Sprintf("content too large: %d bytes (max %d bytes)", len(content), e.maxFileSize*10),
+		}, nil
+	}
+
+	// Record the previous state for undo
+	if err := e.snapshot(fullPath, false); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+	}
+
+	// Ensure parent directory exists
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to create directory: %s", err.Error())}, nil
+	}
+
+	// Write the file
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	return map[string]any{
+		"path":    fullPath,
+		"size":    len(content),
+		"success": true,
+	}, nil
+}
+
+// editFile edits an existing file by replacing a string
+func (e *Executor) editFile(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, ok := args["path"].(string)
+	if !ok || pathArg == "" {
+		return map[string]any{"error": "path is required"}, nil
+	}
+
+	oldString, ok := args["old_string"].(string)
+	if !ok {
+		return map[string]any{"error": "old_string is required"}, nil
+	}
+
+	newString, ok := args["new_string"].(string)
+	if !ok {
+		return map[string]any{"error": "new_string is required"}, nil
+	}
+
+	// Security check
+	fullPath, err := e.resolvePath(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	// Read the file
+	contentBytes, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("file not found: %s", pathArg)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	content := string(contentBytes)
+
+	// Check if old_string exists in the file
+	if !strings.Contains(content, oldString) {
+		return map[string]any{
+			"error": "old_string not found in file",
+			"path":  fullPath,
+		}, nil
+	}
+
+	// Check if old_string is unique
+	count := strings.Count(content, oldString)
+	if count > 1 {
+		return map[string]any{
+			"error":       fmt.Sprintf("old_string found %d times in file, must be unique", count),
+			"path":        fullPath,
+			"occurrences": count,
+		}, nil
+	}
+
+	// Replace the string
+	newContent := strings.Replace(content, oldString, newString, 1)
+
+	// Record the previous state for undo
+	if err := e.snapshot(fullPath, false); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+	}
+
+	// Write the file back
+	if err := os.WriteFile(fullPath, []byte(newContent), 0644); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	return map[string]any{
+		"path":    fullPath,
+		"size":    len(newContent),
+		"success": true,
+	}, nil
+}
+
+// createDirectory creates a directory and any necessary parents
+func (e *Executor) createDirectory(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, ok := args["path"].(string)
+	if !ok || pathArg == "" {
+		return map[string]any{"error": "path is required"}, nil
+	}
+
+	// Security check
+	fullPath, err := e.resolvePath(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	// Record the previous state for undo
+	if err := e.snapshot(fullPath, false); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+	}
+
+	// Create the directory
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	return map[string]any{
+		"path":    fullPath,
+		"success": true,
+	}, nil
+}
+
+// deleteFile deletes a file or directory
+func (e *Executor) deleteFile(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pathArg, ok := args["path"].(string)
+	if !ok || pathArg == "" {
+		return map[string]any{"error": "path is required"}, nil
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	// Security check
+	fullPath, err := e.resolveEntry(pathArg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("path not found: %s", pathArg)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(fullPath)
+		if err != nil {
+			return map[string]any{"error": err.Error()}, nil
+		}
+		if len(entries) > 0 && !recursive {
+			return map[string]any{
+				"error":   "directory is not empty, set recursive to delete it and its contents",
+				"path":    fullPath,
+				"entries": len(entries),
+			}, nil
+		}
+	}
+
+	// Record the previous state for undo
+	if err := e.snapshot(fullPath, true); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+	}
+
+	if err := os.RemoveAll(fullPath); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	return map[string]any{
+		"path":    fullPath,
+		"success": true,
+	}, nil
+}
+
+// movePath moves or renames a file or directory
+func (e *Executor) movePath(ctx context.Context, args map[string]any) (map[string]any, error) {
+	source, ok := args["source"].(string)
+	if !ok || source == "" {
+		return map[string]any{"error": "source is required"}, nil
+	}
+
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return map[string]any{"error": "destination is required"}, nil
+	}
+
+	// Security check
+	srcPath, err := e.resolveEntry(source)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	dstPath, err := e.resolvePath(destination)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("source not found: %s", source)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if _, err := os.Lstat(dstPath); err == nil {
+		return map[string]any{"error": fmt.Sprintf("destination already exists: %s", destination)}, nil
+	}
+
+	if info.IsDir() {
+		if rel, err := filepath.Rel(srcPath, dstPath); err == nil && !strings.HasPrefix(rel, "..") {
+			return map[string]any{"error": "cannot move a directory into itself"}, nil
+		}
+	}
+
+	// Record the previous state for undo
+	for _, path := range []string{srcPath, dstPath} {
+		if err := e.snapshot(path, true); err != nil {
+			return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+		}
+	}
+
+	// Ensure parent directory exists
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to create directory: %s", err.Error())}, nil
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	return map[string]any{
+		"source":      srcPath,
+		"destination": dstPath,
+		"success":     true,
+	}, nil
+}
+
+// copyPath copies a file, or a directory and its contents
+func (e *Executor) copyPath(ctx context.Context, args map[string]any) (map[string]any, error) {
+	source, ok := args["source"].(string)
+	if !ok || source == "" {
+		return map[string]any{"error": "source is required"}, nil
+	}
+
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return map[string]any{"error": "destination is required"}, nil
+	}
+
+	// Security check
+	srcPath, err := e.resolvePath(source)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	dstPath, err := e.resolvePath(destination)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{"error": fmt.Sprintf("source not found: %s", source)}, nil
+		}
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	if _, err := os.Lstat(dstPath); err == nil {
+		return map[string]any{"error": fmt.Sprintf("destination already exists: %s", destination)}, nil
+	}
+
+	if info.IsDir() {
+		if rel, err := filepath.Rel(srcPath, dstPath); err == nil && !strings.HasPrefix(rel, "..") {
+			return map[string]any{"error": "cannot copy a directory into itself"}, nil
+		}
+	}
+
+	// Record the previous state for undo
+	if err := e.snapshot(dstPath, false); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+	}
+
+	files := 0
+	var skipped []string
+	err = filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstPath, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type().IsRegular():
+			files++
+			return copyFile(path, target)
+		default:
+			// Symlinks and special files are not copied
+			skipped = append(skipped, filepath.ToSlash(rel))
+			return nil
+		}
+	})
+	if ctx.Err() != nil {
+		// Leave what was copied in place; /undo removes it
+		return map[string]any{"source": srcPath, "destination": dstPath, "files": files}, ctx.Err()
+	}
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	result := map[string]any{
+		"source":      srcPath,
+		"destination": dstPath,
+		"files":       files,
+		"success":     true,
+	}
+	if len(skipped) > 0 {
+		result["skipped"] = skipped
+	}
+
+	return result, nil
+}
+
+// copyFile copies a regular file, preserving its permissions
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// errPathNotAllowed is returned for paths that escape the working directory
+var errPathNotAllowed = errors.New("path is outside allowed directory")
+
+// resolvePath resolves a path relative to the working directory and returns
+// its real location with every symlink evaluated. It fails if that location
+// is outside the working directory, so a symlink inside the project cannot
+// be used to reach files elsewhere. Every tool must go through this check.
+func (e *Executor) resolvePath(path string) (string, error) {
+	var fullPath string
+	if filepath.IsAbs(path) {
+		fullPath = filepath.Clean(path)
+	} else {
+		fullPath = filepath.Clean(filepath.Join(e.workingDir, path))
+	}
+
+	realPath, err := resolveSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !e.isPathAllowed(realPath) {
+		return "", errPathNotAllowed
+	}
+
+	return realPath, nil
+}
+
+// resolveSymlinks evaluates symlinks in an absolute, clean path. The path
+// need not exist: symlinks are resolved on its deepest existing ancestor and
+// the missing components are appended to the result.
+func resolveSymlinks(path string) (string, error) {
+	existing := path
+	var missing []string
+
+	for {
+		realPath, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{realPath}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		// A link whose target doesn't exist would be followed on write, to
+		// wherever it points, so refuse it outright
+		if _, lerr := os.Lstat(existing); lerr == nil {
+			return "", fmt.Errorf("path is a dangling symlink: %s", existing)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// resolveEntry is like resolvePath but leaves the final path component
+// unresolved, so that deleting or moving a symlink acts on the link itself
+// rather than on its target. The working directory itself is never allowed.
+func (e *Executor) resolveEntry(path string) (string, error) {
+	var fullPath string
+	if filepath.IsAbs(path) {
+		fullPath = filepath.Clean(path)
+	} else {
+		fullPath = filepath.Clean(filepath.Join(e.workingDir, path))
+	}
+
+	parent, err := e.resolvePath(filepath.Dir(fullPath))
+	if err != nil {
+		return "", err
+	}
+
+	entry := filepath.Join(parent, filepath.Base(fullPath))
+	if !e.isPathAllowed(entry) {
+		return "", errPathNotAllowed
+	}
+	return entry, nil
+}
+
+// isPathAllowed checks if a path is lexically within the allowed directory.
+// It does not evaluate symlinks; use resolvePath for that.
+func (e *Executor) isPathAllowed(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// Check if the path is within or equal to the working directory
+	rel, err := filepath.Rel(e.workingDir, absPath)
+	if err != nil {
+		return false
+	}
+
+	// If the relative path is ".." or starts with "../", it's outside the working directory
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isBinaryFile checks if a file appears to be binary by reading first bytes
+func (e *Executor) isBinaryFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// Read first 512 bytes to check for binary content
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil {
+		return false
+	}
+
+	// Check for null bytes (common in binary files)
+	for i := 0; i < n; i++ {
+		if buf[i] == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contextFS stops a directory walk over an fs.FS once its context is done
+type contextFS struct {
+	ctx  context.Context
+	fsys fs.FS
+}
+
+func (c contextFS) Open(name string) (fs.File, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.fsys.Open(name)
+}
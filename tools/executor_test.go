@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReadFilePages(t *testing.T) {
+	e, project, _ := sandbox(t)
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	writeTestFile(t, filepath.Join(project, "ten.txt"), strings.Join(lines, "\n")+"\n")
+
+	result := execute(t, e, "read_file", map[string]any{"path": "ten.txt", "offset": 3.0, "limit": 4.0})
+	if result["content"] != "     3\tline 3\n     4\tline 4\n     5\tline 5\n     6\tline 6\n" {
+		t.Fatalf("unexpected content: %q", result["content"])
+	}
+	if result["truncated"] != true || result["end_line"] != 6 || result["next_offset"] != 7 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if _, ok := result["total_lines"]; ok {
+		t.Fatal("total_lines reported without reading to the end")
+	}
+
+	result = execute(t, e, "read_file", map[string]any{"path": "ten.txt", "offset": 8.0, "limit": 5.0})
+	if result["truncated"] != false || result["end_line"] != 10 || result["total_lines"] != 10 || strings.Count(result["content"].(string), "\n") != 3 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	result = execute(t, e, "read_file", map[string]any{"path": "ten.txt", "offset": 11.0})
+	if msg, _ := result["error"].(string); !strings.Contains(msg, "past the end of the file (10 lines)") {
+		t.Fatalf("expected an error, got %v", result)
+	}
+}
+
+func TestReadFileTruncatesLongLines(t *testing.T) {
+	e, project, _ := sandbox(t)
+	// A line of two-byte runes that doesn't end on maxReadLineLength, then
+	// one longer than the read buffer
+	accented := strings.Repeat("é", maxReadLineLength) + "x"
+	huge := strings.Repeat("a", readChunkSize*3)
+	writeTestFile(t, filepath.Join(project, "long.txt"), "x"+accented+"\n"+huge+"\nlast\r\n")
+
+	result := execute(t, e, "read_file", map[string]any{"path": "long.txt"})
+	content := result["content"].(string)
+	if !utf8.ValidString(content) {
+		t.Fatal("a line was cut in the middle of a rune")
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) != 3 || result["total_lines"] != 3 {
+		t.Fatalf("got %d lines: %v", len(lines), result)
+	}
+	for i, line := range lines[:2] {
+		if !strings.HasSuffix(line, "... [line truncated]") || len(line) > maxReadLineLength+40 {
+			t.Errorf("line %d not truncated: %.40q... (%d bytes)", i+1, line, len(line))
+		}
+	}
+	if lines[2] != "     3\tlast" {
+		t.Errorf("line after the long ones = %q", lines[2])
+	}
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreRule is a single pattern from a .gitignore file
+type ignoreRule struct {
+	base     string // Slash-separated directory of the .gitignore, relative to the root ("" for the root)
+	pattern  string
+	negate   bool // Pattern started with '!'
+	dirOnly  bool // Pattern ended with '/'
+	anchored bool // Pattern contains a '/' and is matched against the full relative path
+}
+
+// gitignore matches paths against the .gitignore files found while walking a tree.
+// It covers the common subset of gitignore syntax: comments, negation,
+// directory-only patterns, anchored patterns and '**'.
+type gitignore struct {
+	root  string
+	rules []ignoreRule
+}
+
+func newGitignore(root string) *gitignore {
+	return &gitignore{root: root}
+}
+
+// load reads the .gitignore in relDir (slash-separated, relative to root), if any
+func (g *gitignore) load(relDir string) {
+	f, err := os.Open(filepath.Join(g.root, filepath.FromSlash(relDir), ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: relDir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// ignored reports whether relPath (slash-separated, relative to root) is ignored.
+// Like git, the last matching rule wins.
+func (g *gitignore) ignored(relPath string, isDir bool) bool {
+	if path.Base(relPath) == ".git" {
+		return true
+	}
+
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		sub := relPath
+		if rule.base != "" {
+			if !strings.HasPrefix(relPath, rule.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(relPath, rule.base+"/")
+		}
+
+		var matched bool
+		if rule.anchored {
+			matched, _ = doublestar.Match(rule.pattern, sub)
+		} else {
+			matched, _ = doublestar.Match(rule.pattern, path.Base(sub))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// WalkProject walks the working directory in lexical order like
+// filepath.WalkDir, skipping .git, whatever the .gitignore files exclude and
+// entries that can't be read. fn is also given the path relative to the
+// working directory, slash-separated ("." for the working directory itself).
+func (e *Executor) WalkProject(ctx context.Context, fn func(path, rel string, d fs.DirEntry) error) error {
+	ignore := newGitignore(e.workingDir)
+	return filepath.WalkDir(e.workingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(e.workingDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case rel == ".":
+			ignore.load("")
+		case d.IsDir():
+			if ignore.ignored(rel, true) {
+				return fs.SkipDir
+			}
+			ignore.load(rel)
+		case ignore.ignored(rel, false):
+			return nil
+		}
+		return fn(path, rel, d)
+	})
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "web", "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, ".gitignore"), `# Comment
+*.log
+!keep.log
+build/
+/root-only.txt
+docs/*.html
+**/generated/**
+\#hash.txt
+`)
+	writeTestFile(t, filepath.Join(root, "web", ".gitignore"), "*.min.js\n/static/\n!important.log\n")
+
+	g := newGitignore(root)
+	g.load("")
+	g.load("web")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".git", true, true},
+		{"app.log", false, true},
+		{"src/deep/app.log", false, true},
+		{"keep.log", false, false},            // Negated
+		{"build", true, true},                 // Directory-only
+		{"build", false, false},               // A file named like the directory
+		{"src/build", true, true},             // Unanchored, so at any depth
+		{"root-only.txt", false, true},        // Anchored with a leading slash
+		{"src/root-only.txt", false, false},   // ...so not below the root
+		{"docs/index.html", false, true},      // Anchored by its inner slash
+		{"src/docs/index.html", false, false}, // ...so only under the root
+		{"a/generated/b/c.go", false, true},   // **
+		{"generated.go", false, false},        // ** needs a directory
+		{"#hash.txt", false, true},            // Escaped '#' is not a comment
+		{"web/app.min.js", false, true},       // Nested .gitignore
+		{"app.min.js", false, false},          // ...only applies under its directory
+		{"web/static", true, true},            // Anchored to the nested directory
+		{"web/sub/static", true, false},       // ...so not deeper
+		{"web/important.log", false, false},   // Nested negation overrides the root
+		{"src/main.go", false, false},         // Not ignored at all
+	}
+	for _, tt := range tests {
+		if got := g.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestGrepSkipsIgnoredAndBinaryFiles(t *testing.T) {
+	e, project, _ := sandbox(t)
+	for _, dir := range []string{"node_modules/lib", "logs"} {
+		if err := os.MkdirAll(filepath.Join(project, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, filepath.Join(project, ".gitignore"), "node_modules/\n*.log\n")
+	writeTestFile(t, filepath.Join(project, "src", "util.go"), "// needle\n")
+	writeTestFile(t, filepath.Join(project, "node_modules", "lib", "index.js"), "needle\n")
+	writeTestFile(t, filepath.Join(project, "logs", "app.log"), "needle\n")
+	writeTestFile(t, filepath.Join(project, "image.bin"), "needle\x00\x01\x02")
+
+	result := execute(t, e, "grep_search", map[string]any{"pattern": "needle"})
+	var files []string
+	for _, match := range result["matches"].([]map[string]any) {
+		files = append(files, match["file"].(string))
+	}
+	if !slices.Equal(files, []string{"src/util.go"}) {
+		t.Fatalf("matched %v, want only src/util.go", files)
+	}
+}
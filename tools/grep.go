@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	maxGrepContextLines = 10
+	maxGrepLineLength   = 500
+)
+
+// errGrepLimit stops the directory walk once enough matches have been found
+var errGrepLimit = errors.New("result limit reached")
+
+// grepSearch searches file contents for a regular expression
+func (e *Executor) grepSearch(ctx context.Context, args map[string]any) (map[string]any, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return map[string]any{"error": "pattern is required"}, nil
+	}
+
+	ignoreCase, _ := args["ignore_case"].(bool)
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return map[string]any{"error": fmt.Sprintf("invalid regex: %s", err.Error())}, nil
+	}
+
+	include, _ := args["glob"].(string)
+	if include != "" && !doublestar.ValidatePattern(include) {
+		return map[string]any{"error": fmt.Sprintf("invalid glob: %s", include)}, nil
+	}
+
+	contextLines, _ := numberArg(args, "context_lines")
+	contextLines = max(0, min(contextLines, maxGrepContextLines))
+
+	var matches []map[string]any
+	filesSearched := 0
+	truncated := false
+
+	err = e.WalkProject(ctx, func(path, rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if include != "" {
+			if ok, _ := doublestar.Match(include, rel); !ok {
+				return nil
+			}
+		}
+		if info, err := d.Info(); err != nil || info.Size() > e.maxFileSize*10 {
+			return nil
+		}
+		if e.isBinaryFile(path) {
+			return nil
+		}
+
+		filesSearched++
+		found, err := grepFile(path, rel, re, contextLines, e.maxResults-len(matches))
+		if err != nil {
+			return nil
+		}
+		matches = append(matches, found...)
+		if len(matches) >= e.maxResults {
+			truncated = true
+			return errGrepLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGrepLimit) && ctx.Err() == nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+
+	// On cancellation, report the matches found so far
+	return map[string]any{
+		"pattern":        args["pattern"],
+		"matches":        matches,
+		"count":          len(matches),
+		"files_searched": filesSearched,
+		"truncated":      truncated,
+	}, ctx.Err()
+}
+
+// grepFile returns up to limit matching lines from a single file
+func grepFile(path, rel string, re *regexp.Regexp, contextLines, limit int) ([]map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var matches []map[string]any
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+
+		match := map[string]any{
+			"file": rel,
+			"line": i + 1,
+			"text": truncateLine(line),
+		}
+		if contextLines > 0 {
+			match["before"] = contextSlice(lines, i-contextLines, i)
+			match["after"] = contextSlice(lines, i+1, i+1+contextLines)
+		}
+		matches = append(matches, match)
+
+		if len(matches) >= limit {
+			break
+		}
+	}
+
+	return matches, nil
+}
+
+// contextSlice returns lines[from:to] clamped to the file, with long lines shortened
+func contextSlice(lines []string, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	out := make([]string, 0, max(to-from, 0))
+	for _, line := range lines[from:to] {
+		out = append(out, truncateLine(line))
+	}
+	return out
+}
+
+// truncateLine shortens very long lines such as minified code
+func truncateLine(line string) string {
+	if len(line) <= maxGrepLineLength {
+		return line
+	}
+	return cutRunes(line, maxGrepLineLength) + "..."
+}
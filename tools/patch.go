@@ -0,0 +1,507 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatchFuzz is the most context lines that may be dropped from either end
+// of a hunk when its full context can't be found
+const maxPatchFuzz = 2
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the set of hunks for one file in a unified diff
+type filePatch struct {
+	oldPath string // "" when the file is being created
+	newPath string // "" when the file is being deleted
+	hunks   []*hunk
+}
+
+// hunk is a single "@@" section of a unified diff
+type hunk struct {
+	header       string
+	oldStart     int
+	newStart     int
+	oldLines     []string // Context and removed lines
+	newLines     []string // Context and added lines
+	leading      int      // Context lines before the first change
+	trailing     int      // Context lines after the last change
+	oldNoNewline bool     // Old side ends without a trailing newline
+	newNoNewline bool     // New side ends without a trailing newline
+}
+
+// patchFailure describes why part of a patch could not be applied
+type patchFailure struct {
+	File   string `json:"file"`
+	Hunk   int    `json:"hunk,omitempty"` // 1-based; 0 for file-level problems
+	Header string `json:"header,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// patchedFile is the computed result for one file, written only once every
+// file in the patch has applied cleanly
+type patchedFile struct {
+	path    string
+	content string
+	created bool
+	hunks   int
+	fuzz    int
+}
+
+// applyPatch applies a unified diff to one or more files. Either every hunk
+// applies and all files are written, or nothing is changed.
+func (e *Executor) applyPatch(ctx context.Context, args map[string]any) (map[string]any, error) {
+	patchText, ok := args["patch"].(string)
+	if !ok || strings.TrimSpace(patchText) == "" {
+		return map[string]any{"error": "patch is required"}, nil
+	}
+
+	patches, err := parsePatch(patchText)
+	if err != nil {
+		return map[string]any{"error": fmt.Sprintf("invalid patch: %s", err.Error())}, nil
+	}
+
+	var results []*patchedFile
+	var failures []patchFailure
+	totalHunks := 0
+	seen := make(map[string]bool)
+
+	for _, fp := range patches {
+		totalHunks += len(fp.hunks)
+		result, fileFailures := e.computePatch(fp)
+		failures = append(failures, fileFailures...)
+		if result == nil {
+			continue
+		}
+		if seen[result.path] {
+			failures = append(failures, patchFailure{File: fp.newPath, Reason: "file appears more than once in the patch"})
+			continue
+		}
+		seen[result.path] = true
+		results = append(results, result)
+	}
+
+	if len(failures) > 0 {
+		return map[string]any{
+			"error":    fmt.Sprintf("patch not applied: %d problem(s) found, no files were changed", len(failures)),
+			"failures": failures,
+			"hunks":    totalHunks,
+		}, nil
+	}
+
+	// Record the previous state for undo
+	for _, r := range results {
+		if err := e.snapshot(r.path, false); err != nil {
+			return map[string]any{"error": fmt.Sprintf("failed to save undo snapshot: %s", err.Error())}, nil
+		}
+	}
+
+	if err := writePatchedFiles(results); err != nil {
+		return map[string]any{"error": fmt.Sprintf("failed to write files, all changes were rolled back: %s", err.Error())}, nil
+	}
+
+	var files []map[string]any
+	for _, r := range results {
+		file := map[string]any{
+			"path":  r.path,
+			"hunks": r.hunks,
+		}
+		if r.created {
+			file["created"] = true
+		}
+		if r.fuzz > 0 {
+			file["fuzz"] = r.fuzz
+		}
+		files = append(files, file)
+	}
+
+	return map[string]any{
+		"files":   files,
+		"hunks":   totalHunks,
+		"success": true,
+	}, nil
+}
+
+// computePatch applies a file's hunks in memory and returns the new content
+func (e *Executor) computePatch(fp *filePatch) (*patchedFile, []patchFailure) {
+	fail := func(reason string) (*patchedFile, []patchFailure) {
+		name := fp.newPath
+		if name == "" {
+			name = fp.oldPath
+		}
+		return nil, []patchFailure{{File: name, Reason: reason}}
+	}
+
+	switch {
+	case fp.newPath == "":
+		return fail("deleting files is not supported by apply_patch, use delete_file")
+	case fp.oldPath != "" && fp.oldPath != fp.newPath:
+		return fail("renaming files is not supported by apply_patch, use move_path first")
+	}
+
+	fullPath, err := e.resolvePath(fp.newPath)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	var original string
+	created := fp.oldPath == ""
+	content, err := os.ReadFile(fullPath)
+	switch {
+	case err == nil && created:
+		return fail("file already exists, but the patch creates it from /dev/null")
+	case err == nil:
+		original = string(content)
+	case os.IsNotExist(err) && !created:
+		return fail("file not found")
+	case !os.IsNotExist(err):
+		return fail(err.Error())
+	}
+
+	// Apply the patch to LF lines and restore the file's line endings after
+	crlf := usesCRLF(original)
+	if crlf {
+		original = strings.ReplaceAll(original, "\r\n", "\n")
+	}
+	lines, trailingNewline := splitLines(original)
+	if created {
+		trailingNewline = true
+	}
+
+	var failures []patchFailure
+	offset := 0   // Net lines added by earlier hunks plus how far they drifted
+	minStart := 0 // Hunks must apply in order without overlapping
+	maxFuzz := 0
+	for i, h := range fp.hunks {
+		// With no old lines the header names the line to insert after
+		base := max(h.oldStart-1, 0)
+		if len(h.oldLines) == 0 {
+			base = h.oldStart
+		}
+
+		pos, lead, oldLen, newLines, fuzz := findHunk(lines, h, base+offset, minStart)
+		if pos < 0 {
+			failures = append(failures, patchFailure{
+				File:   fp.newPath,
+				Hunk:   i + 1,
+				Header: h.header,
+				Reason: fmt.Sprintf("context and removed lines not found in file (expected near line %d, tried fuzz up to %d)", base+1, maxPatchFuzz),
+			})
+			continue
+		}
+
+		lines = append(lines[:pos], append(append([]string{}, newLines...), lines[pos+oldLen:]...)...)
+		offset = (pos - lead) - base + (len(newLines) - oldLen)
+		minStart = pos + len(newLines)
+		maxFuzz = max(maxFuzz, fuzz)
+
+		// A hunk reaching the end of the file decides whether it ends with a newline
+		if pos+len(newLines) == len(lines) {
+			if h.newNoNewline {
+				trailingNewline = false
+			} else if h.oldNoNewline {
+				trailingNewline = true
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, failures
+	}
+
+	newContent := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		newContent += "\n"
+	}
+	if crlf {
+		newContent = strings.ReplaceAll(newContent, "\n", "\r\n")
+	}
+
+	if int64(len(newContent)) > e.maxFileSize*10 {
+		return fail(fmt.Sprintf("patched file too large: %d bytes (max %d bytes)", len(newContent), e.maxFileSize*10))
+	}
+
+	return &patchedFile{
+		path:    fullPath,
+		content: newContent,
+		created: created,
+		hunks:   len(fp.hunks),
+		fuzz:    maxFuzz,
+	}, nil
+}
+
+// findHunk locates where a hunk applies, searching outward from hint but
+// never before minStart. It first looks for an exact match, then ignores
+// trailing whitespace, then drops up to maxPatchFuzz context lines from each
+// end of the hunk. It returns the position, the number of leading context
+// lines dropped, how many existing lines are replaced, the replacement lines
+// and the fuzz used. The position is -1 if nothing matched.
+func findHunk(lines []string, h *hunk, hint, minStart int) (pos, lead, oldLen int, newLines []string, fuzz int) {
+	exact := func(a, b string) bool { return a == b }
+	loose := func(a, b string) bool {
+		return strings.TrimRight(a, " \t\r") == strings.TrimRight(b, " \t\r")
+	}
+
+	for fuzz = 0; fuzz <= maxPatchFuzz; fuzz++ {
+		lead = min(fuzz, h.leading)
+		trail := min(fuzz, h.trailing)
+		if fuzz > 0 && lead == 0 && trail == 0 {
+			break
+		}
+		oldLines := h.oldLines[lead : len(h.oldLines)-trail]
+		newLines = h.newLines[lead : len(h.newLines)-trail]
+
+		for _, match := range []func(a, b string) bool{exact, loose} {
+			if pos = searchLines(lines, oldLines, hint+lead, minStart, match); pos >= 0 {
+				return pos, lead, len(oldLines), newLines, fuzz
+			}
+		}
+	}
+
+	return -1, 0, 0, nil, 0
+}
+
+// searchLines finds want in lines at the position closest to hint
+func searchLines(lines, want []string, hint, minStart int, match func(a, b string) bool) int {
+	last := len(lines) - len(want)
+	if last < minStart {
+		return -1
+	}
+	hint = min(max(hint, minStart), last)
+
+	matchesAt := func(pos int) bool {
+		for i, w := range want {
+			if !match(lines[pos+i], w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for dist := 0; hint-dist >= minStart || hint+dist <= last; dist++ {
+		if pos := hint - dist; pos >= minStart && matchesAt(pos) {
+			return pos
+		}
+		if pos := hint + dist; dist > 0 && pos <= last && matchesAt(pos) {
+			return pos
+		}
+	}
+	return -1
+}
+
+// writePatchedFiles writes every patched file, restoring the originals if any write fails
+func writePatchedFiles(files []*patchedFile) error {
+	type backup struct {
+		path    string
+		content []byte
+		existed bool
+	}
+	var written []backup
+
+	rollback := func() {
+		for i := len(written) - 1; i >= 0; i-- {
+			b := written[i]
+			if b.existed {
+				os.WriteFile(b.path, b.content, 0644)
+			} else {
+				os.Remove(b.path)
+			}
+		}
+	}
+
+	for _, f := range files {
+		b := backup{path: f.path}
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(f.path); err == nil {
+			content, err := os.ReadFile(f.path)
+			if err != nil {
+				rollback()
+				return err
+			}
+			b.content = content
+			b.existed = true
+			mode = info.Mode().Perm()
+		}
+
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			rollback()
+			return err
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), mode); err != nil {
+			rollback()
+			return err
+		}
+		written = append(written, b)
+	}
+
+	return nil
+}
+
+// usesCRLF reports whether content's lines end with "\r\n", judging by the
+// first line
+func usesCRLF(content string) bool {
+	i := strings.IndexByte(content, '\n')
+	return i > 0 && content[i-1] == '\r'
+}
+
+// splitLines splits content into lines and reports whether it ended with a newline
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	content = strings.TrimSuffix(content, "\n")
+	return strings.Split(content, "\n"), trailingNewline
+}
+
+// parsePatch parses a unified diff, as produced by 'diff -u' or 'git diff'
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var patches []*filePatch
+	var current *filePatch
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			current = &filePatch{
+				oldPath: patchPath(line[4:]),
+				newPath: patchPath(lines[i+1][4:]),
+			}
+			if current.oldPath == "" && current.newPath == "" {
+				return nil, fmt.Errorf("line %d: both sides of the file header are /dev/null", i+1)
+			}
+			patches = append(patches, current)
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk before any '--- a/file' / '+++ b/file' header", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.hunks = append(current.hunks, h)
+			i = next - 1
+		}
+		// Anything else (diff --git, index, commentary) is ignored
+	}
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file headers found, expected '--- a/file' and '+++ b/file' lines")
+	}
+	for _, fp := range patches {
+		if len(fp.hunks) == 0 {
+			return nil, fmt.Errorf("no hunks for %s", fp.newPath)
+		}
+	}
+
+	return patches, nil
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns the index of the line after it
+func parseHunk(lines []string, start int) (*hunk, int, error) {
+	m := hunkHeaderRe.FindStringSubmatch(lines[start])
+	if m == nil {
+		return nil, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, lines[start])
+	}
+
+	h := &hunk{header: lines[start]}
+	h.oldStart, _ = strconv.Atoi(m[1])
+	h.newStart, _ = strconv.Atoi(m[3])
+	oldCount, newCount := 1, 1
+	if m[2] != "" {
+		oldCount, _ = strconv.Atoi(m[2])
+	}
+	if m[4] != "" {
+		newCount, _ = strconv.Atoi(m[4])
+	}
+
+	seenChange := false
+	var lastKind byte
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		oldDone := len(h.oldLines) >= oldCount
+		newDone := len(h.newLines) >= newCount
+
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file" applies to the line before it
+			if lastKind != '+' {
+				h.oldNoNewline = true
+			}
+			if lastKind != '-' {
+				h.newNoNewline = true
+			}
+			continue
+		}
+		if oldDone && newDone {
+			break
+		}
+
+		kind := byte(' ')
+		if line != "" {
+			kind = line[0]
+		}
+		text := ""
+		if len(line) > 0 {
+			text = line[1:]
+		}
+
+		switch kind {
+		case ' ':
+			h.oldLines = append(h.oldLines, text)
+			h.newLines = append(h.newLines, text)
+			if seenChange {
+				h.trailing++
+			} else {
+				h.leading++
+			}
+		case '-':
+			h.oldLines = append(h.oldLines, text)
+			seenChange = true
+			h.trailing = 0
+		case '+':
+			h.newLines = append(h.newLines, text)
+			seenChange = true
+			h.trailing = 0
+		default:
+			return nil, 0, fmt.Errorf("line %d: unexpected line in hunk %q", i+1, line)
+		}
+		lastKind = kind
+	}
+
+	if len(h.oldLines) != oldCount || len(h.newLines) != newCount {
+		return nil, 0, fmt.Errorf("hunk %q: expected %d old and %d new lines, found %d and %d",
+			h.header, oldCount, newCount, len(h.oldLines), len(h.newLines))
+	}
+	if !seenChange {
+		h.trailing = 0
+	}
+
+	return h, i, nil
+}
+
+// patchPath extracts a file path from a '---' or '+++' header line
+func patchPath(s string) string {
+	// Drop a trailing timestamp separated by a tab
+	if i := strings.Index(s, "\t"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchBase = "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+
+const patchFour = `--- a/a.txt
++++ b/a.txt
+@@ -2,5 +2,5 @@
+ two
+ three
+-four
++FOUR
+ five
+ six
+`
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // Before the patch
+		patch string
+		want  map[string]string // After the patch; "" means the file must not exist
+		fuzz  int
+		error string // Expected in the error, if the patch must fail
+	}{
+		{
+			name:  "clean apply",
+			files: map[string]string{"a.txt": patchBase},
+			patch: patchFour,
+			want:  map[string]string{"a.txt": "one\ntwo\nthree\nFOUR\nfive\nsix\nseven\n"},
+		},
+		{
+			name:  "offset",
+			files: map[string]string{"a.txt": "x\ny\nz\n" + patchBase},
+			patch: patchFour,
+			want:  map[string]string{"a.txt": "x\ny\nz\none\ntwo\nthree\nFOUR\nfive\nsix\nseven\n"},
+		},
+		{
+			name:  "fuzz",
+			files: map[string]string{"a.txt": "one\nTWO\nthree\nfour\nfive\nsix\nseven\n"},
+			patch: patchFour,
+			want:  map[string]string{"a.txt": "one\nTWO\nthree\nFOUR\nfive\nsix\nseven\n"},
+			fuzz:  1,
+		},
+		{
+			name:  "context mismatch",
+			files: map[string]string{"a.txt": "one\ntwo\nthree\n4\nfive\nsix\nseven\n"},
+			patch: patchFour,
+			want:  map[string]string{"a.txt": "one\ntwo\nthree\n4\nfive\nsix\nseven\n"},
+			error: "no files were changed",
+		},
+		{
+			name:  "create",
+			patch: "--- /dev/null\n+++ b/new/file.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n",
+			want:  map[string]string{"new/file.txt": "hello\nworld\n"},
+		},
+		{
+			name:  "create over an existing file",
+			files: map[string]string{"a.txt": patchBase},
+			patch: "--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+hello\n",
+			want:  map[string]string{"a.txt": patchBase},
+			error: "no files were changed",
+		},
+		{
+			name:  "delete",
+			files: map[string]string{"a.txt": "one\n"},
+			patch: "--- a/a.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-one\n",
+			want:  map[string]string{"a.txt": "one\n"},
+			error: "no files were changed",
+		},
+		{
+			name:  "later file fails",
+			files: map[string]string{"a.txt": patchBase, "b.txt": "alpha\nbeta\n"},
+			patch: patchFour + "--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+new\n--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n alpha\n-gamma\n+delta\n",
+			want:  map[string]string{"a.txt": patchBase, "b.txt": "alpha\nbeta\n", "c.txt": ""},
+			error: "no files were changed",
+		},
+		{
+			name:  "CRLF line endings",
+			files: map[string]string{"a.txt": "a\r\nb\r\nc\r\n"},
+			patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"a.txt": "a\r\nB\r\nc\r\n"},
+		},
+		{
+			name:  "CRLF patch on an LF file",
+			files: map[string]string{"a.txt": "a\nb\nc\n"},
+			patch: "--- a/a.txt\r\n+++ b/a.txt\r\n@@ -2 +2 @@\r\n-b\r\n+B\r\n",
+			want:  map[string]string{"a.txt": "a\nB\nc\n"},
+		},
+		{
+			name:  "no newline at end of file",
+			files: map[string]string{"a.txt": "one\ntwo"},
+			patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+2\n",
+			want:  map[string]string{"a.txt": "one\n2\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, project, _ := sandbox(t)
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(project, name), content)
+			}
+
+			result := execute(t, e, "apply_patch", map[string]any{"patch": tt.patch})
+			if tt.error != "" {
+				if msg, _ := result["error"].(string); !strings.Contains(msg, tt.error) {
+					t.Fatalf("got %v, want an error containing %q", result, tt.error)
+				}
+			} else if result["success"] != true {
+				t.Fatalf("patch failed: %v", result)
+			}
+			if tt.error == "" {
+				files := result["files"].([]map[string]any)
+				if fuzz, _ := files[0]["fuzz"].(int); fuzz != tt.fuzz {
+					t.Errorf("fuzz = %d, want %d", fuzz, tt.fuzz)
+				}
+			}
+
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(project, filepath.FromSlash(name)))
+				switch {
+				case want == "" && !os.IsNotExist(err):
+					t.Errorf("%s exists", name)
+				case want != "" && string(got) != want:
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWritePatchedFilesRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	created := filepath.Join(dir, "sub", "created.txt")
+	writeTestFile(t, existing, "original\n")
+
+	// The last file can't be written because its parent is a regular file
+	err := writePatchedFiles([]*patchedFile{
+		{path: existing, content: "changed\n"},
+		{path: created, content: "new\n", created: true},
+		{path: filepath.Join(existing, "child.txt"), content: "fails\n"},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "original\n" {
+		t.Errorf("existing file not restored: %q", content)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("created file not removed")
+	}
+}
@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Category groups related tools in the system prompt
+type Category string
+
+const (
+	CategoryRead  Category = "Reading"
+	CategoryWrite Category = "Writing"
+	CategoryRun   Category = "Running"
+)
+
+// DefaultTimeout applies to tools whose metadata doesn't set a timeout
+const DefaultTimeout = time.Minute
+
+// Metadata describes how a tool behaves, independent of its declaration
+type Metadata struct {
+	Category      Category
+	ReadOnly      bool          // The tool never modifies files or runs commands, so calls may run concurrently
+	NeedsApproval bool          // The user must confirm each call before it runs
+	Summary       string        // One-line description for the system prompt
+	Timeout       time.Duration // Default limit on a single call; DefaultTimeout if zero
+}
+
+// Tool is a function the model can call. Handlers receive the executor so
+// they can use its sandbox helpers such as ResolvePath and Snapshot, and
+// should stop promptly once ctx is done, returning ctx.Err().
+type Tool interface {
+	Declaration() *genai.FunctionDeclaration
+	Metadata() Metadata
+	Execute(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error)
+}
+
+// FuncTool adapts a declaration and a handler function to the Tool interface
+type FuncTool struct {
+	Decl    *genai.FunctionDeclaration
+	Meta    Metadata
+	Handler func(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error)
+}
+
+func (t *FuncTool) Declaration() *genai.FunctionDeclaration { return t.Decl }
+
+func (t *FuncTool) Metadata() Metadata { return t.Meta }
+
+func (t *FuncTool) Execute(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error) {
+	return t.Handler(ctx, e, args)
+}
+
+var (
+	extensionsMu sync.Mutex
+	extensions   []Tool
+)
+
+// Register adds a tool to every executor created afterwards. It is meant to
+// be called from an init function in a separate package, which is compiled
+// in by importing it from plugins.go rather than by changing main.go.
+func Register(t Tool) {
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	extensions = append(extensions, t)
+}
+
+// registeredExtensions returns the tools added with Register
+func registeredExtensions() []Tool {
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	return append([]Tool(nil), extensions...)
+}
+
+// Registry holds the tools available to the model, in registration order
+type Registry struct {
+	tools map[string]Tool
+	order []string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// Add registers a tool. Tool names must be unique.
+func (r *Registry) Add(t Tool) error {
+	decl := t.Declaration()
+	if decl == nil || decl.Name == "" {
+		return fmt.Errorf("tool has no declaration name")
+	}
+	if _, exists := r.tools[decl.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", decl.Name)
+	}
+	r.tools[decl.Name] = t
+	r.order = append(r.order, decl.Name)
+	return nil
+}
+
+// Get returns the tool with the given name
+func (r *Registry) Get(name string) (Tool, bool) {
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// AllTools returns the declarations of every registered tool
+func (r *Registry) AllTools() []*genai.FunctionDeclaration {
+	decls := make([]*genai.FunctionDeclaration, 0, len(r.order))
+	for _, name := range r.order {
+		decls = append(decls, r.tools[name].Declaration())
+	}
+	return decls
+}
+
+// RequiresApproval reports whether the user must confirm a tool call before it runs
+func (r *Registry) RequiresApproval(name string) bool {
+	t, ok := r.tools[name]
+	return ok && t.Metadata().NeedsApproval
+}
+
+// IsReadOnly reports whether a tool never modifies anything
+func (r *Registry) IsReadOnly(name string) bool {
+	t, ok := r.tools[name]
+	return ok && t.Metadata().ReadOnly
+}
+
+// PromptSection describes the registered tools for the system prompt,
+// grouped by category in the order categories were first registered
+func (r *Registry) PromptSection() string {
+	var categories []Category
+	byCategory := make(map[Category][]Tool)
+	for _, name := range r.order {
+		t := r.tools[name]
+		category := t.Metadata().Category
+		if category == "" {
+			category = "Other"
+		}
+		if _, ok := byCategory[category]; !ok {
+			categories = append(categories, category)
+		}
+		byCategory[category] = append(byCategory[category], t)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Tools Available\n")
+	for _, category := range categories {
+		sb.WriteString("\n")
+		sb.WriteString(string(category))
+		sb.WriteString(":\n")
+		for _, t := range byCategory[category] {
+			meta := t.Metadata()
+			summary := meta.Summary
+			if summary == "" {
+				summary = t.Declaration().Description
+			}
+			sb.WriteString(fmt.Sprintf("- %s: %s", t.Declaration().Name, summary))
+			if meta.NeedsApproval {
+				sb.WriteString(" (the user approves each call)")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
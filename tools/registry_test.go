@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestFuncToolRunsThroughExecutor(t *testing.T) {
+	e, _, _ := sandbox(t)
+	err := e.Register(&FuncTool{
+		Decl: &genai.FunctionDeclaration{
+			Name:       "ticket_lookup",
+			Parameters: &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{"id": {Type: genai.TypeString}}},
+		},
+		Meta: Metadata{Category: "Project", ReadOnly: true, Summary: "Look up a ticket by ID"},
+		Handler: func(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error) {
+			return map[string]any{"id": args["id"], "dir": e.WorkingDir()}, ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := execute(t, e, "ticket_lookup", map[string]any{"id": "T-1"})
+	if result["id"] != "T-1" || result["dir"] != e.WorkingDir() {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if !e.Registry().IsReadOnly("ticket_lookup") || !strings.Contains(e.Registry().PromptSection(), "Project:\n- ticket_lookup: Look up a ticket by ID") {
+		t.Fatalf("tool missing from the prompt:\n%s", e.Registry().PromptSection())
+	}
+	if err := e.Register(&FuncTool{Decl: &genai.FunctionDeclaration{Name: "read_file"}}); err == nil {
+		t.Fatal("expected an error for a duplicate name")
+	}
+}
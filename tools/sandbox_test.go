@@ -0,0 +1,322 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// sandbox builds a project directory next to an "outside" directory holding
+// a secret, and returns an executor rooted at the project
+func sandbox(t *testing.T) (e *Executor, project, outside string) {
+	t.Helper()
+
+	root := t.TempDir()
+	project = filepath.Join(root, "project")
+	outside = filepath.Join(root, "outside")
+	for _, dir := range []string{project, outside, filepath.Join(project, "src")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, filepath.Join(outside, "secret.txt"), "top secret\n")
+	writeTestFile(t, filepath.Join(project, "src", "main.go"), "package main\n")
+
+	e, err := NewExecutor(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, project, outside
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func execute(t *testing.T, e *Executor, name string, args map[string]any) map[string]any {
+	t.Helper()
+	result, err := e.Execute(context.Background(), name, args)
+	if err != nil {
+		t.Fatalf("%s returned error: %v", name, err)
+	}
+	return result
+}
+
+func TestSymlinkedDirectoryIsRejected(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, outside, filepath.Join(project, "escape"))
+
+	tests := []struct {
+		tool string
+		args map[string]any
+	}{
+		{"read_file", map[string]any{"path": "escape/secret.txt"}},
+		{"list_directory", map[string]any{"path": "escape"}},
+		{"write_file", map[string]any{"path": "escape/new.txt", "content": "pwned"}},
+		{"write_file", map[string]any{"path": "escape/secret.txt", "content": "pwned"}},
+		{"edit_file", map[string]any{"path": "escape/secret.txt", "old_string": "top", "new_string": "no"}},
+		{"create_directory", map[string]any{"path": "escape/a/b"}},
+	}
+
+	for _, tt := range tests {
+		result := execute(t, e, tt.tool, tt.args)
+		if result["error"] != errPathNotAllowed.Error() {
+			t.Errorf("%s %v: expected %q, got %v", tt.tool, tt.args, errPathNotAllowed, result)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+		t.Error("write_file created a file outside the working directory")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "a")); !os.IsNotExist(err) {
+		t.Error("create_directory created a directory outside the working directory")
+	}
+	content, _ := os.ReadFile(filepath.Join(outside, "secret.txt"))
+	if string(content) != "top secret\n" {
+		t.Errorf("file outside the working directory was modified: %q", content)
+	}
+}
+
+func TestSymlinkedFileIsRejected(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(project, "src", "config.txt"))
+
+	tests := []struct {
+		tool string
+		args map[string]any
+	}{
+		{"read_file", map[string]any{"path": "src/config.txt"}},
+		{"write_file", map[string]any{"path": "src/config.txt", "content": "pwned"}},
+	}
+
+	for _, tt := range tests {
+		result := execute(t, e, tt.tool, tt.args)
+		if result["error"] != errPathNotAllowed.Error() {
+			t.Errorf("%s: expected %q, got %v", tt.tool, errPathNotAllowed, result)
+		}
+	}
+}
+
+func TestRelativeSymlinkChainIsRejected(t *testing.T) {
+	e, project, _ := sandbox(t)
+	// hop1 -> hop2 -> ../../outside, each link relative and inside the project
+	symlink(t, "../../outside", filepath.Join(project, "src", "hop2"))
+	symlink(t, "src/hop2", filepath.Join(project, "hop1"))
+
+	result := execute(t, e, "read_file", map[string]any{"path": "hop1/secret.txt"})
+	if result["error"] != errPathNotAllowed.Error() {
+		t.Errorf("expected %q, got %v", errPathNotAllowed, result)
+	}
+}
+
+func TestDanglingSymlinkIsRejected(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, filepath.Join(outside, "created-by-agent.txt"), filepath.Join(project, "dangling"))
+
+	result := execute(t, e, "write_file", map[string]any{"path": "dangling", "content": "pwned"})
+	if result["error"] == nil {
+		t.Fatalf("expected an error, got %v", result)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "created-by-agent.txt")); !os.IsNotExist(err) {
+		t.Error("write_file followed a dangling symlink outside the working directory")
+	}
+}
+
+func TestSymlinkInsideProjectIsAllowed(t *testing.T) {
+	e, project, _ := sandbox(t)
+	symlink(t, "src", filepath.Join(project, "alias"))
+
+	result := execute(t, e, "read_file", map[string]any{"path": "alias/main.go"})
+	if result["error"] != nil {
+		t.Fatalf("unexpected error: %v", result["error"])
+	}
+
+	result = execute(t, e, "write_file", map[string]any{"path": "alias/new/file.go", "content": "package new\n"})
+	if result["error"] != nil {
+		t.Fatalf("unexpected error: %v", result["error"])
+	}
+	if _, err := os.Stat(filepath.Join(project, "src", "new", "file.go")); err != nil {
+		t.Errorf("expected file written through internal symlink: %v", err)
+	}
+}
+
+func TestWorkingDirectoryBehindSymlink(t *testing.T) {
+	_, project, _ := sandbox(t)
+	link := filepath.Join(t.TempDir(), "project-link")
+	symlink(t, project, link)
+
+	e, err := NewExecutor(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"src/main.go", filepath.Join(link, "src", "main.go")} {
+		result := execute(t, e, "read_file", map[string]any{"path": path})
+		if result["error"] != nil {
+			t.Errorf("read_file %s: unexpected error: %v", path, result["error"])
+		}
+	}
+}
+
+func TestLexicalEscapesAreRejected(t *testing.T) {
+	e, _, outside := sandbox(t)
+
+	for _, path := range []string{"../outside/secret.txt", "src/../../outside/secret.txt", filepath.Join(outside, "secret.txt")} {
+		result := execute(t, e, "read_file", map[string]any{"path": path})
+		if result["error"] != errPathNotAllowed.Error() {
+			t.Errorf("read_file %s: expected %q, got %v", path, errPathNotAllowed, result)
+		}
+	}
+}
+
+func TestDotDotPrefixedNameIsAllowed(t *testing.T) {
+	e, project, _ := sandbox(t)
+	writeTestFile(t, filepath.Join(project, "..notes"), "hello\n")
+
+	result := execute(t, e, "read_file", map[string]any{"path": "..notes"})
+	if result["error"] != nil {
+		t.Errorf("unexpected error: %v", result["error"])
+	}
+}
+
+func TestGlobSkipsSymlinksOutside(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, outside, filepath.Join(project, "escape"))
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(project, "leak.txt"))
+	writeTestFile(t, filepath.Join(project, "notes.txt"), "hello\n")
+
+	result := execute(t, e, "glob_search", map[string]any{"pattern": "**/*.txt"})
+	matches, _ := result["matches"].([]string)
+	if !slices.Equal(matches, []string{"notes.txt"}) {
+		t.Errorf("expected only notes.txt, got %v", matches)
+	}
+}
+
+func TestGlobDoesNotWalkSymlinkedDirectories(t *testing.T) {
+	e, project, outside := sandbox(t)
+	// Following these links would never finish: every level doubles
+	symlink(t, ".", filepath.Join(outside, "a"))
+	symlink(t, ".", filepath.Join(outside, "b"))
+	symlink(t, outside, filepath.Join(project, "escape"))
+	if err := e.SetTimeout("glob_search", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result := execute(t, e, "glob_search", map[string]any{"pattern": "**/*.txt"})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("glob_search took %s", elapsed)
+	}
+	if result["error"] != nil || result["count"] != 0 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	result = execute(t, e, "glob_search", map[string]any{"pattern": "escape/*.txt"})
+	if result["count"] != 0 {
+		t.Fatalf("matched a file outside the working directory: %v", result)
+	}
+}
+
+func TestDeleteSymlinkRemovesLinkOnly(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, outside, filepath.Join(project, "escape"))
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(project, "leak.txt"))
+
+	for _, path := range []string{"escape", "leak.txt"} {
+		result := execute(t, e, "delete_file", map[string]any{"path": path, "recursive": true})
+		if result["success"] != true {
+			t.Fatalf("delete_file %s: %v", path, result)
+		}
+		if _, err := os.Lstat(filepath.Join(project, path)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(outside, "secret.txt")); err != nil || string(content) != "top secret\n" {
+		t.Fatalf("the link's target was touched: %q, %v", content, err)
+	}
+
+	// Deleting through a link must fail
+	symlink(t, outside, filepath.Join(project, "escape"))
+	result := execute(t, e, "delete_file", map[string]any{"path": "escape/secret.txt"})
+	if result["error"] != errPathNotAllowed.Error() {
+		t.Errorf("expected %q, got %v", errPathNotAllowed, result)
+	}
+}
+
+func TestMoveAndCopyCannotEscape(t *testing.T) {
+	e, project, outside := sandbox(t)
+	symlink(t, outside, filepath.Join(project, "escape"))
+
+	for _, tool := range []string{"move_path", "copy_path"} {
+		for _, args := range []map[string]any{
+			{"source": "src/main.go", "destination": "../outside/main.go"},
+			{"source": "src/main.go", "destination": "escape/main.go"},
+			{"source": "src", "destination": filepath.Join(outside, "src")},
+			{"source": "escape/secret.txt", "destination": "secret.txt"},
+			{"source": "../outside/secret.txt", "destination": "secret.txt"},
+		} {
+			result := execute(t, e, tool, args)
+			if result["error"] != errPathNotAllowed.Error() {
+				t.Errorf("%s %v: expected %q, got %v", tool, args, errPathNotAllowed, result)
+			}
+		}
+	}
+
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 1 {
+		t.Errorf("files were written outside the working directory: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(project, "src", "main.go")); err != nil {
+		t.Errorf("the source was moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(project, "secret.txt")); !os.IsNotExist(err) {
+		t.Error("a file from outside was copied in")
+	}
+}
+
+func TestDeleteNonEmptyDirectoryNeedsRecursive(t *testing.T) {
+	e, project, _ := sandbox(t)
+
+	result := execute(t, e, "delete_file", map[string]any{"path": "src"})
+	if result["error"] == nil || result["entries"] != 1 {
+		t.Fatalf("expected an error, got %v", result)
+	}
+	if _, err := os.Stat(filepath.Join(project, "src", "main.go")); err != nil {
+		t.Fatalf("the directory was deleted: %v", err)
+	}
+
+	result = execute(t, e, "delete_file", map[string]any{"path": "src", "recursive": true})
+	if result["success"] != true {
+		t.Fatalf("recursive delete failed: %v", result)
+	}
+
+	result = execute(t, e, "delete_file", map[string]any{"path": "."})
+	if result["error"] == nil {
+		t.Fatalf("deleted the working directory: %v", result)
+	}
+}
+
+func TestMoveDirectoryIntoItself(t *testing.T) {
+	e, project, _ := sandbox(t)
+
+	result := execute(t, e, "move_path", map[string]any{"source": "src", "destination": "src/sub/src"})
+	if result["error"] != "cannot move a directory into itself" {
+		t.Fatalf("expected a clear error, got %v", result)
+	}
+	if _, err := os.Stat(filepath.Join(project, "src", "sub")); !os.IsNotExist(err) {
+		t.Error("the destination's parent was created inside the source")
+	}
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// runShellCommand runs a command through the shell in the working directory
+func (e *Executor) runShellCommand(ctx context.Context, args map[string]any) (map[string]any, error) {
+	command, ok := args["command"].(string)
+	if !ok || command == "" {
+		return map[string]any{"error": "command is required"}, nil
+	}
+
+	timeout := e.shellTimeout
+	if secs, ok := numberArg(args, "timeout_seconds"); ok && secs > 0 {
+		timeout = time.Duration(secs) * time.Second
+	}
+	// The tool's own timeout, which may be set lower in the config, caps
+	// whatever was asked for
+	timeout = min(timeout, e.maxShellTimeout, e.Timeout("run_shell_command"))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shellPath(), "-c", command)
+	cmd.Dir = e.workingDir
+	setProcessGroup(cmd)
+	// Don't let children that left the process group and hold the pipes
+	// open block us forever
+	cmd.WaitDelay = 2 * time.Second
+
+	stdout := newCappedBuffer(e.maxOutputSize)
+	stderr := newCappedBuffer(e.maxOutputSize)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+
+	exitCode := 0
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			exitCode = exitErr.ExitCode()
+		case timedOut:
+			exitCode = -1
+		default:
+			return map[string]any{"error": fmt.Sprintf("failed to run command: %s", err.Error())}, nil
+		}
+	}
+
+	result := map[string]any{
+		"command":     command,
+		"exit_code":   exitCode,
+		"stdout":      stdout.String(),
+		"stderr":      stderr.String(),
+		"duration_ms": duration.Milliseconds(),
+	}
+	if stdout.Truncated() || stderr.Truncated() {
+		result["truncated"] = true
+	}
+	if timedOut {
+		result["timed_out"] = true
+		result["error"] = fmt.Sprintf("command timed out after %s", timeout)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return result, ctx.Err()
+	}
+
+	return result, nil
+}
+
+// shellPath returns the shell used to run commands, preferring bash
+func shellPath() string {
+	if path, err := exec.LookPath("bash"); err == nil {
+		return path
+	}
+	return "sh"
+}
+
+// numberArg reads a numeric argument, which arrives as float64 from JSON
+func numberArg(args map[string]any, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// cappedBuffer keeps the beginning and end of a stream, dropping the middle
+// once more than limit bytes have been written
+type cappedBuffer struct {
+	limit   int
+	head    []byte
+	tail    []byte
+	dropped int
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	headLimit := b.limit / 2
+
+	if room := headLimit - len(b.head); room > 0 {
+		take := min(room, len(p))
+		b.head = append(b.head, p[:take]...)
+		p = p[take:]
+	}
+
+	if len(p) > 0 {
+		b.tail = append(b.tail, p...)
+		tailLimit := b.limit - headLimit
+		if over := len(b.tail) - tailLimit; over > 0 {
+			b.dropped += over
+			b.tail = append(b.tail[:0], b.tail[over:]...)
+		}
+	}
+
+	return n, nil
+}
+
+// Truncated reports whether any output was dropped
+func (b *cappedBuffer) Truncated() bool {
+	return b.dropped > 0
+}
+
+func (b *cappedBuffer) String() string {
+	if b.dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, b.dropped, b.tail)
+}
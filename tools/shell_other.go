@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// setProcessGroup leaves cmd as it is; only the shell itself is killed on
+// cancellation
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellCommandReportsExitCode(t *testing.T) {
+	e, _, _ := sandbox(t)
+
+	result := execute(t, e, "run_shell_command", map[string]any{"command": "echo out; echo err >&2; exit 3"})
+	if result["exit_code"] != 3 || result["stdout"] != "out\n" || result["stderr"] != "err\n" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if _, ok := result["error"]; ok {
+		t.Fatalf("a failing command is not an error: %v", result)
+	}
+}
+
+func TestShellTimeoutKillsChildren(t *testing.T) {
+	e, project, _ := sandbox(t)
+	e.shellTimeout = 300 * time.Millisecond
+
+	// The background child would create the marker after the timeout
+	start := time.Now()
+	result := execute(t, e, "run_shell_command", map[string]any{"command": "(sleep 1; touch marker) & sleep 10"})
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Fatalf("command took %s to return after a 300ms timeout", elapsed)
+	}
+	if result["timed_out"] != true || result["exit_code"] != -1 {
+		t.Fatalf("expected a timed out result, got %v", result)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(project, "marker")); err == nil {
+		t.Fatal("a child of the shell outlived the timeout")
+	}
+}
+
+func TestCappedBufferKeepsHeadAndTail(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"under the limit", []string{"abc", "def"}, "abcdef"},
+		{"at the limit", []string{"abcdefghij"}, "abcdefghij"},
+		{"one write", []string{"abcdefghijklmnopqrstuvwxyz"}, "abcde\n... [16 bytes truncated] ...\nvwxyz"},
+		{"many writes", strings.Split("abcdefghijklmnopqrstuvwxyz", ""), "abcde\n... [16 bytes truncated] ...\nvwxyz"},
+	} {
+		b := newCappedBuffer(10)
+		for _, w := range tt.writes {
+			if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+				t.Fatalf("%s: Write returned %d, %v", tt.name, n, err)
+			}
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if b.Truncated() != strings.Contains(tt.want, "truncated") {
+			t.Errorf("%s: Truncated() = %v", tt.name, b.Truncated())
+		}
+	}
+}
+
+func TestShellTimeoutReportsEffectiveLimit(t *testing.T) {
+	e, _, _ := sandbox(t)
+	if err := e.SetTimeout("run_shell_command", 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	result := execute(t, e, "run_shell_command", map[string]any{"command": "sleep 10", "timeout_seconds": 60.0})
+	if result["timed_out"] != true || result["error"] != "command timed out after 300ms" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+}
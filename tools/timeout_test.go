@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestSetTimeoutOverridesDefault(t *testing.T) {
+	e, _, _ := sandbox(t)
+
+	if got := e.Timeout("glob_search"); got != time.Minute {
+		t.Fatalf("default glob_search timeout = %s, want 1m", got)
+	}
+	if err := e.SetTimeout("glob_search", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Timeout("glob_search"); got != 5*time.Second {
+		t.Fatalf("overridden timeout = %s, want 5s", got)
+	}
+	if err := e.SetTimeout("glob_search", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Timeout("glob_search"); got != time.Minute {
+		t.Fatalf("restored timeout = %s, want 1m", got)
+	}
+	if err := e.SetTimeout("no_such_tool", time.Second); err == nil {
+		t.Fatal("expected an error for an unknown tool")
+	}
+}
+
+func TestCancelledContextStopsTool(t *testing.T) {
+	e, _, _ := sandbox(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := e.Execute(ctx, "glob_search", map[string]any{"pattern": "**/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["cancelled"] != true {
+		t.Fatalf("expected cancelled result, got %v", result)
+	}
+}
+
+func TestToolTimeoutKillsShellCommand(t *testing.T) {
+	e, _, _ := sandbox(t)
+	if err := e.SetTimeout("run_shell_command", 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result := execute(t, e, "run_shell_command", map[string]any{"command": "sleep 10"})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command ran for %s after its timeout", elapsed)
+	}
+	if result["timed_out"] != true {
+		t.Fatalf("expected timed_out result, got %v", result)
+	}
+}
+
+func TestInterruptKillsShellCommand(t *testing.T) {
+	e, _, _ := sandbox(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	result, err := e.Execute(ctx, "run_shell_command", map[string]any{"command": "sleep 10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["cancelled"] != true {
+		t.Fatalf("expected cancelled result, got %v", result)
+	}
+}
+
+func TestCompletedToolIsNotMarkedCancelled(t *testing.T) {
+	e, _, _ := sandbox(t)
+	wait := func(stop bool) *FuncTool {
+		name := "finishes"
+		if stop {
+			name = "stops"
+		}
+		return &FuncTool{
+			Decl: &genai.FunctionDeclaration{Name: name},
+			Handler: func(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error) {
+				<-ctx.Done()
+				if stop {
+					return map[string]any{"partial": true}, ctx.Err()
+				}
+				return map[string]any{"success": true}, nil
+			},
+		}
+	}
+	for _, tool := range []*FuncTool{wait(false), wait(true)} {
+		if err := e.Register(tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := e.Execute(ctx, "finishes", nil)
+	if err != nil || result["success"] != true || result["error"] != nil || result["cancelled"] != nil {
+		t.Fatalf("a tool that finished its work was reported as %v, %v", result, err)
+	}
+	result, err = e.Execute(ctx, "stops", nil)
+	if err != nil || result["partial"] != true || result["cancelled"] != true {
+		t.Fatalf("a tool that stopped early was reported as %v, %v", result, err)
+	}
+}
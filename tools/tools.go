@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Tool declarations for file system operations
+
+var ReadFileTool = &genai.FunctionDeclaration{
+	Name:        "read_file",
+	Description: "Read the contents of a file at the given path. Use this to examine source code, configuration files, documentation, or any text file. Returns line-numbered content (the number and a tab precede each line, and are not part of the file) along with a truncated flag, and total_lines once the end of the file is reached. Large files are returned a page at a time: when truncated is true, call again with offset set to next_offset.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file path to read (absolute or relative to working directory)",
+			},
+			"offset": {
+				Type:        genai.TypeInteger,
+				Description: "The 1-based line number to start reading from (default 1)",
+				Minimum:     genai.Ptr(1.0),
+			},
+			"limit": {
+				Type:        genai.TypeInteger,
+				Description: "The maximum number of lines to return (default and max 2000)",
+				Minimum:     genai.Ptr(1.0),
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+var ListDirectoryTool = &genai.FunctionDeclaration{
+	Name:        "list_directory",
+	Description: "List files and directories at the given path. Returns names with type indicators (directories end with /). Useful for exploring project structure.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The directory path to list. Use '.' or empty for current directory.",
+			},
+		},
+		Required: []string{},
+	},
+}
+
+var GlobSearchTool = &genai.FunctionDeclaration{
+	Name:        "glob_search",
+	Description: "Find files matching a glob pattern. Useful for finding all files of a certain type. Examples: '*.go' for Go files in current dir, '**/*.go' for all Go files recursively, 'src/**/*.ts' for TypeScript files in src.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"pattern": {
+				Type:        genai.TypeString,
+				Description: "Glob pattern to match (e.g., '*.go', '**/*.ts', 'src/**/*.js')",
+			},
+		},
+		Required: []string{"pattern"},
+	},
+}
+
+var GrepSearchTool = &genai.FunctionDeclaration{
+	Name:        "grep_search",
+	Description: "Search file contents for a regular expression (RE2 syntax). Returns the file, line number and text of each matching line. Files ignored by .gitignore and binary files are skipped. Use this to find where a symbol is defined or used instead of reading files one by one.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"pattern": {
+				Type:        genai.TypeString,
+				Description: "Regular expression to search for (e.g., 'func NewExecutor', 'TODO|FIXME')",
+			},
+			"glob": {
+				Type:        genai.TypeString,
+				Description: "Optional glob limiting which files are searched (e.g., '**/*.go', 'internal/**')",
+			},
+			"ignore_case": {
+				Type:        genai.TypeBoolean,
+				Description: "Match case-insensitively (default false)",
+			},
+			"context_lines": {
+				Type:        genai.TypeInteger,
+				Description: "Number of lines of context to include before and after each match (default 0, max 10)",
+				Minimum:     genai.Ptr(0.0),
+				Maximum:     genai.Ptr(10.0),
+			},
+		},
+		Required: []string{"pattern"},
+	},
+}
+
+var WriteFileTool = &genai.FunctionDeclaration{
+	Name:        "write_file",
+	Description: "Write content to a file, creating it if it doesn't exist or overwriting if it does. Use this to create new files or completely replace file contents. For partial edits, use edit_file instead.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file path to write to (relative to working directory)",
+			},
+			"content": {
+				Type:        genai.TypeString,
+				Description: "The content to write to the file",
+			},
+		},
+		Required: []string{"path", "content"},
+	},
+}
+
+var EditFileTool = &genai.FunctionDeclaration{
+	Name:        "edit_file",
+	Description: "Edit an existing file by replacing a specific string with new content. The old_string must match exactly (including whitespace and indentation) and must not include the line-number prefixes shown by read_file. Use this for surgical edits to existing files. For creating new files or full rewrites, use write_file.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file path to edit (relative to working directory)",
+			},
+			"old_string": {
+				Type:        genai.TypeString,
+				Description: "The exact string to find and replace (must match exactly, including whitespace)",
+			},
+			"new_string": {
+				Type:        genai.TypeString,
+				Description: "The string to replace old_string with",
+			},
+		},
+		Required: []string{"path", "old_string", "new_string"},
+	},
+}
+
+var ApplyPatchTool = &genai.FunctionDeclaration{
+	Name:        "apply_patch",
+	Description: "Apply a unified diff (as produced by 'diff -u' or 'git diff') that may change several files at once. Use this for multi-hunk or multi-file changes instead of many edit_file calls. Hunks are located by their context lines, tolerating shifted line numbers, trailing whitespace differences and up to 2 mismatched context lines. The patch is atomic: if any hunk fails, no file is changed and the failures are reported. New files use '--- /dev/null'; deletions and renames are not supported.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"patch": {
+				Type:        genai.TypeString,
+				Description: "The unified diff, with '--- a/path' and '+++ b/path' headers (relative to working directory) followed by '@@' hunks",
+			},
+		},
+		Required: []string{"patch"},
+	},
+}
+
+var CreateDirectoryTool = &genai.FunctionDeclaration{
+	Name:        "create_directory",
+	Description: "Create a new directory (and any necessary parent directories). Use this before writing files to new directories.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The directory path to create (relative to working directory)",
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+var DeleteFileTool = &genai.FunctionDeclaration{
+	Name:        "delete_file",
+	Description: "Delete a file or directory. Non-empty directories are refused unless recursive is true. Deleting a symlink removes the link, not its target. The user must approve every deletion.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file or directory path to delete (relative to working directory)",
+			},
+			"recursive": {
+				Type:        genai.TypeBoolean,
+				Description: "Delete a non-empty directory and everything in it (default false)",
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+var MovePathTool = &genai.FunctionDeclaration{
+	Name:        "move_path",
+	Description: "Move or rename a file or directory. Parent directories of the destination are created as needed. Fails if the destination already exists. The user must approve every move.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"source": {
+				Type:        genai.TypeString,
+				Description: "The file or directory to move (relative to working directory)",
+			},
+			"destination": {
+				Type:        genai.TypeString,
+				Description: "The new path (relative to working directory)",
+			},
+		},
+		Required: []string{"source", "destination"},
+	},
+}
+
+var CopyPathTool = &genai.FunctionDeclaration{
+	Name:        "copy_path",
+	Description: "Copy a file, or a directory and all of its contents. Parent directories of the destination are created as needed. Fails if the destination already exists. Symlinks inside a copied directory are skipped.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"source": {
+				Type:        genai.TypeString,
+				Description: "The file or directory to copy (relative to working directory)",
+			},
+			"destination": {
+				Type:        genai.TypeString,
+				Description: "The path of the copy (relative to working directory)",
+			},
+		},
+		Required: []string{"source", "destination"},
+	},
+}
+
+var RunShellCommandTool = &genai.FunctionDeclaration{
+	Name:        "run_shell_command",
+	Description: "Run a shell command in the working directory and return its exit code, stdout and stderr. Use this to build, test, lint or format the project (e.g. 'go build ./...', 'go test ./...'). The user must approve every command before it runs. Commands are non-interactive and are killed when the timeout expires.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"command": {
+				Type:        genai.TypeString,
+				Description: "The shell command to run (executed with 'bash -c' in the working directory)",
+			},
+			"timeout_seconds": {
+				Type:        genai.TypeInteger,
+				Description: "Optional timeout in seconds (default 120, max 600)",
+				Minimum:     genai.Ptr(1.0),
+				Maximum:     genai.Ptr(600.0),
+			},
+		},
+		Required: []string{"command"},
+	},
+}
+
+// builtin adapts an executor method to a FuncTool handler
+func builtin(method func(*Executor, context.Context, map[string]any) (map[string]any, error)) func(context.Context, *Executor, map[string]any) (map[string]any, error) {
+	return func(ctx context.Context, e *Executor, args map[string]any) (map[string]any, error) {
+		return method(e, ctx, args)
+	}
+}
+
+// builtinTools are the tools every executor starts with, in prompt order
+var builtinTools = []Tool{
+	&FuncTool{
+		Decl:    ReadFileTool,
+		Meta:    Metadata{Category: CategoryRead, ReadOnly: true, Summary: "Read file contents with line numbers (large files are paged; use offset/limit)", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).readFile),
+	},
+	&FuncTool{
+		Decl:    ListDirectoryTool,
+		Meta:    Metadata{Category: CategoryRead, ReadOnly: true, Summary: "List directory contents", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).listDirectory),
+	},
+	&FuncTool{
+		Decl:    GlobSearchTool,
+		Meta:    Metadata{Category: CategoryRead, ReadOnly: true, Summary: "Find files by pattern (e.g., '**/*.go')", Timeout: time.Minute},
+		Handler: builtin((*Executor).globSearch),
+	},
+	&FuncTool{
+		Decl:    GrepSearchTool,
+		Meta:    Metadata{Category: CategoryRead, ReadOnly: true, Summary: "Search file contents with a regex (skips .gitignored and binary files)", Timeout: time.Minute},
+		Handler: builtin((*Executor).grepSearch),
+	},
+	&FuncTool{
+		Decl:    WriteFileTool,
+		Meta:    Metadata{Category: CategoryWrite, Summary: "Create new files or overwrite existing files", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).writeFile),
+	},
+	&FuncTool{
+		Decl:    EditFileTool,
+		Meta:    Metadata{Category: CategoryWrite, Summary: "Make surgical edits by replacing specific strings (old_string must be unique)", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).editFile),
+	},
+	&FuncTool{
+		Decl:    ApplyPatchTool,
+		Meta:    Metadata{Category: CategoryWrite, Summary: "Apply a unified diff across one or more files, all or nothing", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).applyPatch),
+	},
+	&FuncTool{
+		Decl:    CreateDirectoryTool,
+		Meta:    Metadata{Category: CategoryWrite, Summary: "Create directories", Timeout: 30 * time.Second},
+		Handler: builtin((*Executor).createDirectory),
+	},
+	&FuncTool{
+		Decl:    DeleteFileTool,
+		Meta:    Metadata{Category: CategoryWrite, NeedsApproval: true, Summary: "Delete a file or directory (recursive required for non-empty directories)", Timeout: time.Minute},
+		Handler: builtin((*Executor).deleteFile),
+	},
+	&FuncTool{
+		Decl:    MovePathTool,
+		Meta:    Metadata{Category: CategoryWrite, NeedsApproval: true, Summary: "Move or rename a file or directory", Timeout: time.Minute},
+		Handler: builtin((*Executor).movePath),
+	},
+	&FuncTool{
+		Decl:    CopyPathTool,
+		Meta:    Metadata{Category: CategoryWrite, Summary: "Copy a file or directory", Timeout: 2 * time.Minute},
+		Handler: builtin((*Executor).copyPath),
+	},
+	&FuncTool{
+		Decl:    RunShellCommandTool,
+		Meta:    Metadata{Category: CategoryRun, NeedsApproval: true, Summary: "Run a shell command (build, test, lint) and get its exit code and output", Timeout: 10 * time.Minute},
+		Handler: builtin((*Executor).runShellCommand),
+	},
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// validateArgs checks a tool call's arguments against the tool's parameter
+// schema and returns a description of every problem found
+func validateArgs(schema *genai.Schema, args map[string]any) []string {
+	if schema == nil {
+		if len(args) > 0 {
+			return []string{"tool takes no arguments"}
+		}
+		return nil
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return validateValue(schema, args, "")
+}
+
+// validateValue checks a single value against a schema. path names the value
+// in messages, e.g. "args.items[2]".
+func validateValue(schema *genai.Schema, value any, path string) []string {
+	name := path
+	if name == "" {
+		name = "arguments"
+	}
+
+	if value == nil {
+		if schema.Nullable != nil && *schema.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must not be null", name)}
+	}
+
+	var problems []string
+	switch schema.Type {
+	case genai.TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return []string{typeProblem(name, "a string", value)}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %s", name, s, strings.Join(schema.Enum, ", ")))
+		}
+
+	case genai.TypeInteger, genai.TypeNumber:
+		n, ok := toFloat(value)
+		if !ok {
+			return []string{typeProblem(name, "a number", value)}
+		}
+		if schema.Type == genai.TypeInteger && n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s: must be an integer, got %v", name, n)}
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: must be at least %v, got %v", name, *schema.Minimum, n))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: must be at most %v, got %v", name, *schema.Maximum, n))
+		}
+
+	case genai.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return []string{typeProblem(name, "a boolean", value)}
+		}
+
+	case genai.TypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			return []string{typeProblem(name, "an array", value)}
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				problems = append(problems, validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", name, i))...)
+			}
+		}
+
+	case genai.TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{typeProblem(name, "an object", value)}
+		}
+
+		for _, field := range schema.Required {
+			if _, ok := obj[field]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field", joinPath(path, field)))
+			}
+		}
+
+		// Sort keys so the messages are stable
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			propSchema, ok := schema.Properties[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: unexpected field", joinPath(path, key)))
+				continue
+			}
+			problems = append(problems, validateValue(propSchema, obj[key], joinPath(path, key))...)
+		}
+	}
+
+	return problems
+}
+
+// toFloat converts the numeric types that can appear in decoded arguments
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func typeProblem(name, want string, got any) string {
+	return fmt.Sprintf("%s: must be %s, got %s", name, want, jsonTypeName(got))
+}
+
+// jsonTypeName names the JSON type of a decoded value
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
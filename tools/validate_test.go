@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestValidateArgs(t *testing.T) {
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path":  {Type: genai.TypeString},
+			"mode":  {Type: genai.TypeString, Enum: []string{"read", "write"}},
+			"count": {Type: genai.TypeInteger, Minimum: genai.Ptr(1.0), Maximum: genai.Ptr(10.0)},
+			"ratio": {Type: genai.TypeNumber},
+			"force": {Type: genai.TypeBoolean},
+			"edits": {
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{"line": {Type: genai.TypeInteger}, "text": {Type: genai.TypeString}},
+					Required:   []string{"line"},
+				},
+			},
+		},
+		Required: []string{"path"},
+	}
+
+	tests := []struct {
+		name string
+		args string // As JSON, so numbers decode to float64 the way they arrive from the model
+		want []string
+	}{
+		{"valid", `{"path": "a.go", "mode": "read", "count": 3, "ratio": 0.5, "force": true, "edits": [{"line": 1, "text": "x"}]}`, nil},
+		{"missing required field", `{"mode": "read"}`, []string{"path: missing required field"}},
+		{"wrong type", `{"path": 42, "force": "yes"}`, []string{"force: must be a boolean, got string", "path: must be a string, got number"}},
+		{"integer given a fraction", `{"path": "a.go", "count": 2.5}`, []string{"count: must be an integer, got 2.5"}},
+		{"whole number for a number", `{"path": "a.go", "ratio": 2}`, nil},
+		{"enum", `{"path": "a.go", "mode": "append"}`, []string{`mode: "append" is not one of read, write`}},
+		{"below minimum", `{"path": "a.go", "count": 0}`, []string{"count: must be at least 1, got 0"}},
+		{"above maximum", `{"path": "a.go", "count": 11}`, []string{"count: must be at most 10, got 11"}},
+		{"nested", `{"path": "a.go", "edits": [{"line": 1}, {"text": "x"}, {"line": "2"}, "x"]}`, []string{
+			"edits[1].line: missing required field",
+			"edits[2].line: must be a number, got string",
+			"edits[3]: must be an object, got string",
+		}},
+		{"not an array", `{"path": "a.go", "edits": {"line": 1}}`, []string{"edits: must be an array, got object"}},
+		{"unexpected field", `{"path": "a.go", "recursive": true}`, []string{"recursive: unexpected field"}},
+		{"null", `{"path": null}`, []string{"path: must not be null"}},
+	}
+
+	for _, tt := range tests {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		if got := validateArgs(schema, args); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateArgsWithoutSchema(t *testing.T) {
+	if got := validateArgs(nil, nil); got != nil {
+		t.Errorf("got %q for no arguments", got)
+	}
+	if got := validateArgs(nil, map[string]any{"x": 1.0}); len(got) != 1 {
+		t.Errorf("got %q, want a problem", got)
+	}
+}